@@ -0,0 +1,160 @@
+// Package cache remembers what the files found by earlier scans held, so that
+// a rescan only reads and parses the files that changed since.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+
+	"org.gkh/findcert/cmd"
+)
+
+// Version of the cache file format. A cache of another version is ignored
+// and rebuilt.
+const version = 3
+
+// The contents of files read by earlier scans, by path. A Cache can be used by
+// several scans at the same time.
+type Cache struct {
+	path string
+	hash bool
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// The paths read since Open
+	used map[string]bool
+}
+
+// What a file held when it was last read, and how to tell it has not changed
+type entry struct {
+	Extension string `json:"extension"`
+	Device    uint64 `json:"device,omitempty"`
+	Inode     uint64 `json:"inode,omitempty"`
+	Size      int64  `json:"size"`
+	// Modification time in nanoseconds since the Unix epoch
+	ModTime int64 `json:"mtime_ns"`
+	// SHA-256 of the contents, when the cache compares them
+	Hash     string            `json:"sha256,omitempty"`
+	Contents *cmd.FileContents `json:"contents"`
+}
+
+// The cache as it is saved
+type cacheFile struct {
+	Version int               `json:"version"`
+	Entries map[string]*entry `json:"entries"`
+}
+
+// Opens the cache saved at path, or an empty one if there is none yet. With
+// hash set, a file must also have the SHA-256 it had when it was cached, for
+// file systems whose modification times can not be trusted; every file is
+// then read again and the cache only saves parsing it.
+func Open(path string, hash bool) (*Cache, error) {
+	c := &Cache{path: path, hash: hash, entries: make(map[string]*entry), used: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	var saved cacheFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse cache %s: %w", path, err)
+	}
+	if saved.Version == version && saved.Entries != nil {
+		c.entries = saved.Entries
+	}
+	return c, nil
+}
+
+// Returns what the file found with the given extension holds: from the cache
+// if the file has not changed since it was cached, and by reading it
+// otherwise. hit tells which.
+func (c *Cache) Read(extension, path string) (contents *cmd.FileContents, hit bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	device, inode := fileIdentity(info)
+	current := &entry{
+		Extension: extension,
+		Device:    device,
+		Inode:     inode,
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UnixNano(),
+	}
+
+	var data []byte
+	if c.hash {
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, false, err
+		}
+		sum := sha256.Sum256(data)
+		current.Hash = hex.EncodeToString(sum[:])
+	}
+
+	c.mu.Lock()
+	cached := c.entries[path]
+	c.used[path] = true
+	c.mu.Unlock()
+	if cached != nil && cached.matches(current) {
+		return cached.Contents, true, nil
+	}
+
+	if data != nil {
+		current.Contents = cmd.ParseContents(extension, path, data)
+	} else if current.Contents, err = cmd.ReadContents(extension, path); err != nil {
+		return nil, false, err
+	}
+	c.mu.Lock()
+	c.entries[path] = current
+	c.mu.Unlock()
+	return current.Contents, false, nil
+}
+
+// Is the file the same as when e was cached?
+func (e *entry) matches(current *entry) bool {
+	return e.Contents != nil &&
+		e.Extension == current.Extension &&
+		e.Device == current.Device &&
+		e.Inode == current.Inode &&
+		e.Size == current.Size &&
+		e.ModTime == current.ModTime &&
+		e.Hash == current.Hash
+}
+
+// Writes the cache back to its file. With prune set, the files not read
+// since Open are left out, as after a complete scan they no longer exist.
+// The cache is written to a temporary file first and renamed, so a crash
+// while saving leaves the previous cache in place.
+func (c *Cache) Save(prune bool) error {
+	c.mu.Lock()
+	saved := cacheFile{Version: version, Entries: make(map[string]*entry, len(c.entries))}
+	for path, e := range c.entries {
+		if !prune || c.used[path] {
+			saved.Entries[path] = e
+		}
+	}
+	data, err := json.Marshal(saved)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
@@ -1,75 +1,195 @@
 package cli
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"time"
+	"strings"
 
-	"org.gkh/findcert/cmd"
-	"org.gkh/findcert/config"
 	"org.gkh/findcert/ui"
 )
 
-func Execute(path string, outputFile string) {
-	spinner := ui.NewSpinner()
-	spinner.Start("Searching for certificate files...")
+var (
+	// Returned by commands that have already reported why they failed
+	errFailed = errors.New("command failed")
+	// Returned when the command line is wrong and the usage has been printed
+	errUsage = errors.New("usage error")
+	// Returned when SIGINT or SIGTERM stopped the command after it saved what it could
+	errInterrupted = errors.New("interrupted")
+)
 
-	results, err := cmd.ListCertificates(path)
+// The findcert command line, writing to Stdout and Stderr instead of the process streams
+type App struct {
+	Version string
+	GitSHA  string
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
 
-	spinner.Stop()
+// A subcommand with its own flags and help text
+type command struct {
+	name     string
+	summary  string
+	usage    string
+	examples []string
+	// Defines the command's flags and returns the handler to run with the positional arguments
+	setup func(a *App, fs *flag.FlagSet) func(args []string) error
+}
 
-	if err != nil {
-		fmt.Printf("Error walking directory tree: %v\n", err)
-		os.Exit(1)
-	}
+// Subcommands in the order they are listed in the help text
+var commands = []*command{
+	scanCommand,
+	watchCommand,
+	checkCommand,
+	identifyCommand,
+	noextCommand,
+	diffCommand,
+	baselineCommand,
+	serveMetricsCommand,
+	historyCommand,
+	serveCommand,
+	schemaCommand,
+	versionCommand,
+}
 
-	// Count total files and create final result
-	totalFiles := 0
-	for _, result := range results {
-		totalFiles += len(result.Files)
+// Runs the subcommand named by args[0] and returns the process exit code.
+// Arguments starting with a flag run the scan, as findcert did before subcommands.
+func (a *App) Run(args []string) int {
+	if len(args) == 0 {
+		a.printUsage(a.Stderr)
+		return 2
 	}
 
-	searchResult := config.SearchResult{
-		SearchPath: path,
-		TotalFiles: totalFiles,
-		Results:    results,
-		SearchTime: time.Now(),
+	name := args[0]
+	if strings.HasPrefix(name, "-") {
+		if name == "-h" || name == "-help" || name == "--help" {
+			a.printUsage(a.Stdout)
+			return 0
+		}
+		name = "scan"
+	} else {
+		args = args[1:]
 	}
 
-	// Print results to console
-	for _, result := range results {
-		fmt.Printf("%sFiles with extension %s:%s\n", ui.ColorGreen, result.Type, ui.ColorReset)
-		if len(result.Files) == 0 {
-			fmt.Println("No files found")
-		} else {
-			for _, file := range result.Files {
-				displayPath := file.Path
-				if len(file.Path) > 50 {
-					displayPath = file.Path[:23] + "..." + file.Path[len(file.Path)-24:]
-				}
-				fmt.Printf("%s (Size: %d bytes, Modified: %s)\n",
-					displayPath, file.Size, file.ModifiedTime.Format(time.RFC3339))
+	if name == "help" {
+		if len(args) > 0 {
+			if c := findCommand(args[0]); c != nil {
+				a.printCommandUsage(a.Stdout, c, nil)
+				return 0
 			}
 		}
-		fmt.Println()
+		a.printUsage(a.Stdout)
+		return 0
 	}
 
-	// Write results to JSON file
-	jsonData, err := json.MarshalIndent(searchResult, "", "  ")
-	if err != nil {
-		fmt.Printf("Error creating JSON: %v\n", err)
-		os.Exit(1)
+	c := findCommand(name)
+	if c == nil {
+		fmt.Fprintf(a.Stderr, "Unknown command %q\n\n", name)
+		a.printUsage(a.Stderr)
+		return 2
 	}
 
-	err = os.WriteFile(outputFile, jsonData, 0644)
-	if err != nil {
-		fmt.Printf("Error writing JSON file: %v\n", err)
-		os.Exit(1)
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+	fs.Usage = func() { a.printCommandUsage(a.Stderr, c, fs) }
+
+	run := c.setup(a, fs)
+	positional, err := parseArgs(fs, args)
+	if err == nil {
+		err = run(positional)
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, errFailed):
+		return 1
+	case errors.Is(err, errInterrupted):
+		return 130
+	default:
+		fmt.Fprintf(a.Stderr, "Error: %v\n", err)
+		return 1
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *App) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%sCertificate File Finder%s\n\n", ui.ColorYellow, ui.ColorReset)
+	fmt.Fprintln(w, "Usage: findcert <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nRun \"findcert help <command>\" for the flags and examples of a command.")
+}
+
+func (a *App) printCommandUsage(w io.Writer, c *command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", c.usage, c.summary)
+
+	if fs == nil {
+		fs = flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(a, fs)
+	}
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
 	}
 
-	// Print summary
-	fmt.Printf("%sSummary:%s\n", ui.ColorYellow, ui.ColorReset)
-	fmt.Printf("Total certificate files found: %d\n", totalFiles)
-	fmt.Println("Results have been saved to results.json")
+	if len(c.examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range c.examples {
+			fmt.Fprintf(w, "  %s\n", example)
+		}
+	}
+}
+
+// Parses flags that appear before or after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Reads a file with one entry per line, skipping blanks and # comments
+func readList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			items = append(items, line)
+		}
+	}
+	return items, nil
 }
@@ -0,0 +1,765 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func newTestApp() (*App, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &App{Version: "1.2.3", Stdout: stdout, Stderr: stderr}, stdout, stderr
+}
+
+// Writes a self-signed certificate for the DNS names to path
+func writeTestCertificate(t *testing.T, path string, dnsNames ...string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+}
+
+func TestRun_Version(t *testing.T) {
+	app, stdout, _ := newTestApp()
+
+	if code := app.Run([]string{"version"}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+	if !strings.HasPrefix(stdout.String(), "1.2.3 ") {
+		t.Errorf("Expected version output, got %q", stdout.String())
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	app, _, stderr := newTestApp()
+
+	if code := app.Run([]string{"frobnicate"}); code != 2 {
+		t.Errorf("Expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Unknown command") {
+		t.Errorf("Expected an unknown command message, got %q", stderr.String())
+	}
+}
+
+func TestRun_HelpListsExamples(t *testing.T) {
+	app, stdout, _ := newTestApp()
+
+	if code := app.Run([]string{"help", "scan"}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+	for _, expected := range []string{"Usage: findcert scan", "-output", "Examples:"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected help to contain %q, got %q", expected, stdout.String())
+		}
+	}
+}
+
+func TestRun_CheckHost(t *testing.T) {
+	certPath := filepath.Join(t.TempDir(), "api.pem")
+	writeTestCertificate(t, certPath, "api.example.com")
+
+	app, stdout, _ := newTestApp()
+	// Flags may follow the certificate path
+	if code := app.Run([]string{"check", certPath, "-host", "api.example.com"}); code != 0 {
+		t.Errorf("Expected exit code 0 for a matching host, got %d: %s", code, stdout.String())
+	}
+
+	app, _, _ = newTestApp()
+	if code := app.Run([]string{"check", "-host", "www.example.com", certPath}); code != 1 {
+		t.Errorf("Expected exit code 1 for a host the certificate does not cover, got %d", code)
+	}
+}
+
+func TestRun_CheckMissingFile(t *testing.T) {
+	app, _, stderr := newTestApp()
+
+	if code := app.Run([]string{"check", filepath.Join(t.TempDir(), "missing.pem")}); code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !strings.HasPrefix(stderr.String(), "Error:") {
+		t.Errorf("Expected an error message, got %q", stderr.String())
+	}
+}
+
+func TestRun_ScanWritesResults(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	outputFile := filepath.Join(t.TempDir(), "out.json")
+
+	app, stdout, stderr := newTestApp()
+	code := app.Run([]string{"scan", "-output", outputFile, "-hosts", "www.example.com,mail.example.com", tempDir})
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "mail.example.com: ") {
+		t.Errorf("Expected host coverage in the output, got %q", stdout.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+	if len(result.Hosts) != 2 || len(result.Hosts[1].Paths) != 0 {
+		t.Errorf("Expected mail.example.com to be uncovered, got %+v", result.Hosts)
+	}
+}
+
+func TestRun_ScanCacheHits(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	outDir := t.TempDir()
+	cachePath := filepath.Join(outDir, "cache.json")
+	outputFile := filepath.Join(outDir, "out.json")
+
+	for run, want := range []string{"from the cache: 0, files read: 1", "from the cache: 1, files read: 0"} {
+		app, stdout, stderr := newTestApp()
+		if code := app.Run([]string{"scan", "-cache", cachePath, "-output", outputFile, tempDir}); code != 0 {
+			t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Run %d: expected %q in the summary, got %q", run+1, want, stdout.String())
+		}
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if result.Cache == nil || result.Cache.Hits != 1 {
+		t.Errorf("Expected one cache hit in the results, got %+v", result.Cache)
+	}
+	certificates := 0
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			certificates += len(file.Certificates)
+		}
+	}
+	if certificates != 1 {
+		t.Errorf("Expected the cached certificate to be checked, got %d certificates", certificates)
+	}
+}
+
+// Tells when the first write has happened
+type signalWriter struct {
+	bytes.Buffer
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	if w.Len() == 0 {
+		defer close(w.written)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWatch_WebhookAndStdout(t *testing.T) {
+	dir := t.TempDir()
+	received := make(chan map[string]any, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		received <- event
+	}))
+	defer hook.Close()
+
+	app, stdout, _ := newTestApp()
+	stderr := &signalWriter{written: make(chan struct{})}
+	app.Stderr = stderr
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.Watch(ctx, WatchOptions{
+			Dirs:     []string{dir},
+			Settings: config.DefaultSettings(),
+			Poll:     true,
+			Interval: 20 * time.Millisecond,
+			Webhook:  hook.URL,
+		})
+	}()
+	<-stderr.written
+
+	writeTestCertificate(t, filepath.Join(dir, "www.crt"), "www.example.com")
+	select {
+	case event := <-received:
+		if event["type"] != "added" || event["path"] != filepath.Join(dir, "www.crt") {
+			t.Errorf("Expected www.crt to be added, got %v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the webhook")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"type":"added"`) {
+		t.Errorf("Expected the event on standard output, got %q", stdout.String())
+	}
+}
+
+// Holds up the first write, to let a scan time out while it is running
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	if w.Len() == 0 {
+		time.Sleep(w.delay)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestRun_ScanTimeoutWritesPartialResults(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "a.crt"), "a.example.com")
+	writeTestCertificate(t, filepath.Join(tempDir, "b.crt"), "b.example.com")
+
+	app, _, stderr := newTestApp()
+	stdout := &slowWriter{delay: 200 * time.Millisecond}
+	app.Stdout = stdout
+	code := app.Run([]string{"scan", "-timeout", "10ms", "-format", "ndjson", "-output", "-", tempDir})
+	if code != 1 {
+		t.Fatalf("Expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "timed out") {
+		t.Errorf("Expected the timeout to be reported, got %q", stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	var summary struct {
+		Type       string `json:"type"`
+		TotalFiles int    `json:"total_files"`
+		Incomplete bool   `json:"incomplete"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+		t.Fatalf("Failed to decode summary: %v", err)
+	}
+	if summary.Type != "summary" || !summary.Incomplete || summary.TotalFiles != 1 {
+		t.Errorf("Expected an incomplete summary of the first file, got %+v", summary)
+	}
+}
+
+func TestRun_ScanTimeoutJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "a.crt"), "a.example.com")
+	outputFile := filepath.Join(t.TempDir(), "out.json")
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-timeout", "1ns", "-output", outputFile, tempDir}); code != 1 {
+		t.Fatalf("Expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "timed out") {
+		t.Errorf("Expected the timeout to be reported, got %q", stderr.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if !result.Incomplete {
+		t.Error("Expected the results to be marked incomplete")
+	}
+}
+
+func TestRun_ScanResumesFromCheckpoint(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "a.crt"), "a.example.com")
+	writeTestCertificate(t, filepath.Join(tempDir, "b.crt"), "b.example.com")
+	outDir := t.TempDir()
+	outputFile := filepath.Join(outDir, "out.json")
+	checkpoint := filepath.Join(outDir, "checkpoint.json")
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-timeout", "1ns", "-checkpoint", checkpoint, "-output", outputFile, tempDir}); code != 1 {
+		t.Fatalf("Expected exit code 1, got %d: %s", code, stderr.String())
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("Expected the checkpoint of the stopped scan to be kept: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "-resume "+checkpoint) {
+		t.Errorf("Expected how to resume to be printed, got %q", stderr.String())
+	}
+
+	app, stdout, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-resume", checkpoint, "-output", outputFile, tempDir}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Resuming from the checkpoint") {
+		t.Errorf("Expected the resume to be reported, got %q", stdout.String())
+	}
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if result.Incomplete || result.TotalFiles != 2 {
+		t.Errorf("Expected a complete scan of both files, got %d files, incomplete %v", result.TotalFiles, result.Incomplete)
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed after a complete scan, got %v", err)
+	}
+}
+
+func TestRun_IdentifyPEM(t *testing.T) {
+	certPath := filepath.Join(t.TempDir(), "noextension")
+	writeTestCertificate(t, certPath, "id.example.com")
+
+	app, stdout, _ := newTestApp()
+	if code := app.Run([]string{"identify", certPath}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "PEM Encoded Certificate") {
+		t.Errorf("Expected the file to be identified as a PEM certificate, got %q", stdout.String())
+	}
+}
+
+func TestRun_ScanConfigAndFailOn(t *testing.T) {
+	tempDir := t.TempDir()
+	// Expires within the default 30 day warning window
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	writeTestCertificate(t, filepath.Join(tempDir, "skipped.pem"), "skipped.example.com")
+
+	outputFile := filepath.Join(t.TempDir(), "out.json")
+	configFile := filepath.Join(t.TempDir(), "findcert.yaml")
+	settings := "exclude: [skipped.pem]\noutput:\n  file: " + outputFile + "\npolicy:\n  fail_on: warning\n"
+	if err := os.WriteFile(configFile, []byte(settings), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-config", configFile, tempDir}); code != 1 {
+		t.Errorf("Expected exit code 1 for a warning, got %d: %s", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected the excluded file to be skipped, got %d files", result.TotalFiles)
+	}
+
+	// The flag takes precedence over the file
+	app, _, stderr = newTestApp()
+	if code := app.Run([]string{"scan", "-config", configFile, "-fail-on", "error", tempDir}); code != 0 {
+		t.Errorf("Expected exit code 0 with -fail-on error, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRun_ScanConfiguredExtensions(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.cert"), "www.example.com")
+	writeTestCertificate(t, filepath.Join(tempDir, "mail.pem"), "mail.example.com")
+
+	outputFile := filepath.Join(t.TempDir(), "out.json")
+	configFile := filepath.Join(t.TempDir(), "findcert.yaml")
+	settings := "extensions: [.cert, .pem]\noutput:\n  file: " + outputFile + "\n"
+	if err := os.WriteFile(configFile, []byte(settings), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-config", configFile, tempDir}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	certificates := map[string]int{}
+	for _, extension := range result.Results {
+		for _, file := range extension.Files {
+			certificates[extension.Type] += len(file.Certificates)
+		}
+	}
+	if certificates[".cert"] != 1 || certificates[".pem"] != 1 {
+		t.Errorf("Expected a certificate in each configured extension, got %v", certificates)
+	}
+}
+
+func TestRun_ScanNotifiesWebhooks(t *testing.T) {
+	tempDir := t.TempDir()
+	// Expires within the default 30 day warning window
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+
+	var paths, bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(data))
+	}))
+	defer server.Close()
+
+	configFile := filepath.Join(t.TempDir(), "findcert.yaml")
+	settings := "output:\n  file: " + filepath.Join(t.TempDir(), "out.json") + "\nnotify:\n" +
+		"  - webhook: " + server.URL + "/slack\n    template: slack\n    severity: warning\n" +
+		"  - webhook: " + server.URL + "/errors\n    severity: error\n"
+	if err := os.WriteFile(configFile, []byte(settings), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-config", configFile, tempDir}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if len(paths) != 1 || paths[0] != "/slack" {
+		t.Fatalf("Expected only the warning webhook to be notified, got %v", paths)
+	}
+	var payload struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if !strings.Contains(payload.Text, "expiring-soon") || !strings.Contains(payload.Text, tempDir) {
+		t.Errorf("Expected the finding in the message, got %q", payload.Text)
+	}
+}
+
+func TestRun_ScanNotificationFailureHidesWebhookPath(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rejected", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	configFile := filepath.Join(t.TempDir(), "findcert.yaml")
+	settings := "output:\n  file: " + filepath.Join(t.TempDir(), "out.json") + "\nnotify:\n" +
+		"  - webhook: " + server.URL + "/services/T000/B000/secret-token\n    attempts: 1\n"
+	if err := os.WriteFile(configFile, []byte(settings), 0644); err != nil {
+		t.Fatalf("Failed to write configuration: %v", err)
+	}
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-config", configFile, tempDir}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Warning: notification to webhook "+server.URL+" failed") {
+		t.Errorf("Expected the failure to be reported, got %q", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "secret-token") {
+		t.Errorf("Expected the webhook path to be left out, got %q", stderr.String())
+	}
+}
+
+func TestRun_ScanCSV(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	outputFile := filepath.Join(t.TempDir(), "inventory.csv")
+
+	app, stdout, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-format", "csv", "-output", outputFile, tempDir}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Results have been saved to "+outputFile) {
+		t.Errorf("Expected the real output path in the summary, got %q", stdout.String())
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "path,type,subject") {
+		t.Errorf("Expected a header and one row, got %q", string(data))
+	}
+}
+
+func TestRun_ScanNDJSONToStdout(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "a.crt"), "a.example.com")
+	writeTestCertificate(t, filepath.Join(tempDir, "b.pem"), "b.example.com")
+
+	app, stdout, stderr := newTestApp()
+	code := app.Run([]string{"scan", "-format", "ndjson", "-output", "-", "-hosts", "c.example.com", tempDir})
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	// Standard output holds nothing but records
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	types := make(map[string]int)
+	var last map[string]any
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Line is not JSON: %q", line)
+		}
+		types[record["type"].(string)]++
+		last = record
+	}
+
+	if types["file"] != 2 {
+		t.Errorf("Expected 2 file records, got %d", types["file"])
+	}
+	if types["finding"] == 0 {
+		t.Error("Expected findings for the uncovered host and expiring certificates")
+	}
+	if last["type"] != "summary" || last["total_files"] != float64(2) {
+		t.Errorf("Expected a summary record last, got %v", last)
+	}
+}
+
+func TestRun_DiffJSON(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	oldFile := filepath.Join(t.TempDir(), "old.json")
+	newFile := filepath.Join(t.TempDir(), "new.json")
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-output", oldFile, tempDir}); code != 0 {
+		t.Fatalf("Scan failed: %s", stderr.String())
+	}
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	if code := app.Run([]string{"scan", "-output", newFile, tempDir}); code != 0 {
+		t.Fatalf("Scan failed: %s", stderr.String())
+	}
+
+	app, stdout, stderr := newTestApp()
+	if code := app.Run([]string{"diff", "-format", "json", oldFile, newFile}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var diff config.ResultDiff
+	if err := json.Unmarshal(stdout.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to decode diff: %v", err)
+	}
+	if len(diff.Renewed) != 1 || len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("Expected the reissued certificate to be a renewal, got %+v", diff)
+	}
+}
+
+func TestRun_BaselineSuppressesFindings(t *testing.T) {
+	tempDir := t.TempDir()
+	// Expires within the default 30 day warning window
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	resultsFile := filepath.Join(t.TempDir(), "results.json")
+	baselineFile := filepath.Join(t.TempDir(), "baseline.yaml")
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-output", resultsFile, "-fail-on", "warning", tempDir}); code != 1 {
+		t.Fatalf("Expected the expiring certificate to fail the scan, got %d: %s", code, stderr.String())
+	}
+	if code := app.Run([]string{"baseline", "create", "-justification", "Renewal booked", "-output", baselineFile, resultsFile}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	for _, format := range []string{"json", "ndjson"} {
+		app, _, stderr = newTestApp()
+		output := filepath.Join(t.TempDir(), "results."+format)
+		code := app.Run([]string{"scan", "-baseline", baselineFile, "-format", format, "-output", output, "-fail-on", "warning", tempDir})
+		if code != 0 {
+			t.Errorf("%s: expected accepted findings not to fail the scan, got %d: %s", format, code, stderr.String())
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Failed to read results: %v", err)
+		}
+		if !strings.Contains(string(data), "Renewal booked") {
+			t.Errorf("%s: expected the suppression and its justification in the results", format)
+		}
+	}
+
+	// Once the suppression runs out the finding is back
+	baseline, err := config.LoadBaseline(baselineFile)
+	if err != nil {
+		t.Fatalf("Failed to load baseline: %v", err)
+	}
+	for i := range baseline.Suppressions {
+		baseline.Suppressions[i].Expires = "2020-01-01"
+	}
+	out, err := os.Create(baselineFile)
+	if err != nil {
+		t.Fatalf("Failed to rewrite baseline: %v", err)
+	}
+	baseline.Write(out)
+	out.Close()
+
+	app, _, stderr = newTestApp()
+	if code := app.Run([]string{"scan", "-baseline", baselineFile, "-output", resultsFile, "-fail-on", "warning", tempDir}); code != 1 {
+		t.Errorf("Expected an expired suppression to fail the scan again, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "expired on 2020-01-01") {
+		t.Errorf("Expected a warning about the expired suppression, got %q", stderr.String())
+	}
+}
+
+func TestMetricsServer(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+
+	server := newMetricsServer(ScanOptions{Path: tempDir, Settings: config.DefaultSettings()})
+	if err := server.rescan(context.Background()); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`findcert_cert_not_after_seconds{path="` + filepath.Join(tempDir, "www.crt") + `",subject="CN=www.example.com"`,
+		"findcert_files 1\n",
+		"findcert_scans_total 1\n",
+		"findcert_last_scan_success 1\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in\n%s", expected, body)
+		}
+	}
+
+	// A failed scan keeps the last metrics and is counted
+	server.opts.Path = filepath.Join(tempDir, "missing")
+	if err := server.rescan(context.Background()); err == nil {
+		t.Fatal("Expected scanning a missing path to fail")
+	}
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body = recorder.Body.String()
+	if !strings.Contains(body, "findcert_files 1\n") || !strings.Contains(body, "findcert_scan_failures_total 1\n") ||
+		!strings.Contains(body, "findcert_last_scan_success 0\n") {
+		t.Errorf("Expected the last metrics and a failure count, got\n%s", body)
+	}
+}
+
+func TestServeMetrics_StopsWithContext(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app, stdout, stderr := newTestApp()
+	done := make(chan error, 1)
+	go func() {
+		done <- app.ServeMetrics(ctx, ScanOptions{Path: tempDir, Settings: config.DefaultSettings()}, "127.0.0.1:0", time.Millisecond)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop once its context is done")
+	}
+	if !strings.Contains(stdout.String(), "Serving metrics for "+tempDir) || stderr.Len() != 0 {
+		t.Errorf("Unexpected output %q, %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRun_ScanDatabaseAndHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	database := filepath.Join(t.TempDir(), "inventory.db")
+
+	app, _, stderr := newTestApp()
+	if code := app.Run([]string{"scan", "-database", database, "-output", filepath.Join(t.TempDir(), "r.json"), tempDir}); code != 0 {
+		t.Fatalf("Scan failed: %s", stderr.String())
+	}
+	if code := app.Run([]string{"scan", "-database", database, "-format", "ndjson", "-output", filepath.Join(t.TempDir(), "r.ndjson"), tempDir}); code != 0 {
+		t.Fatalf("Streamed scan failed: %s", stderr.String())
+	}
+
+	app, stdout, stderr := newTestApp()
+	if code := app.Run([]string{"history", "-database", database, "-format", "json"}); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var certs []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &certs); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if len(certs) != 1 || certs[0]["subject"] != "CN=www.example.com" || certs[0]["scans"] != float64(2) {
+		t.Errorf("Expected one certificate seen in both scans, got %v", certs)
+	}
+}
+
+func TestRun_BaselineCreateScansDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestCertificate(t, filepath.Join(tempDir, "www.crt"), "www.example.com")
+	baselineFile := filepath.Join(t.TempDir(), "baseline.yaml")
+	// The configured baseline does not exist yet and must not hide the findings
+	configFile := filepath.Join(t.TempDir(), "findcert.yaml")
+	if err := os.WriteFile(configFile, []byte("policy:\n  baseline: "+baselineFile+"\n  fail_on: warning\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	for _, pass := range []string{"create", "recreate"} {
+		app, stdout, stderr := newTestApp()
+		if code := app.Run([]string{"baseline", "create", "-config", configFile, "-output", baselineFile, tempDir}); code != 0 {
+			t.Fatalf("%s: expected exit code 0, got %d: %s", pass, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "Wrote 1 suppression(s)") {
+			t.Errorf("%s: expected the expiring certificate to be accepted, got %q", pass, stdout.String())
+		}
+	}
+
+	app, _, stderr := newTestApp()
+	output := filepath.Join(t.TempDir(), "results.json")
+	if code := app.Run([]string{"scan", "-config", configFile, "-output", output, tempDir}); code != 0 {
+		t.Errorf("Expected accepted findings not to fail the scan, got %d: %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/scan"
+)
+
+var baselineCommand = &command{
+	name:    "baseline",
+	summary: "Create a baseline file accepting the findings of a scan",
+	usage:   "findcert baseline create [flags] <results.json|directory>",
+	examples: []string{
+		"findcert baseline create results.json",
+		"findcert baseline create -config findcert.yaml /etc/ssl",
+		"findcert baseline create -expires 2027-03-31 -justification 'Legacy appliances, see RISK-42' -output accepted.yaml results.json",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		output := fs.String("output", "findcert-baseline.yaml", "Baseline file to write, - for standard output")
+		justification := fs.String("justification", "Accepted when the baseline was created", "Justification recorded for every suppression")
+		expires := fs.String("expires", "90", "Expiry of the suppressions, as a date (YYYY-MM-DD) or a number of days from now")
+		flags := addSelectionFlags(fs)
+
+		return func(args []string) error {
+			if len(args) != 2 || args[0] != "create" {
+				fs.Usage()
+				return errUsage
+			}
+			expiry, err := parseExpiry(*expires, time.Now())
+			if err != nil {
+				return err
+			}
+
+			info, err := os.Stat(args[1])
+			if err != nil {
+				return fmt.Errorf("path does not exist: %s", args[1])
+			}
+			var result *config.SearchResult
+			if info.IsDir() {
+				settings, err := flags.searchSettings(fs, func(*config.Settings, string) {})
+				if err != nil {
+					return err
+				}
+				result, err = a.scanForBaseline(args[1], settings)
+				if err != nil {
+					return err
+				}
+			} else if result, err = cmd.LoadSearchResult(args[1]); err != nil {
+				return err
+			}
+			return a.CreateBaseline(result, *output, *justification, expiry)
+		}
+	},
+}
+
+// Searches the directory for the findings a new baseline accepts. No baseline
+// applies, so the findings an earlier one accepted are kept.
+func (a *App) scanForBaseline(path string, settings config.Settings) (*config.SearchResult, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	scanner, err := scan.New(scan.Options{Settings: settings})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := scanContext(0)
+	defer cancel()
+	result, err := scanner.Scan(ctx, absPath)
+	if err != nil {
+		// Accepting the findings of part of the tree would leave the rest failing
+		if err == context.Canceled {
+			fmt.Fprintln(a.Stderr, "Scan interrupted, no baseline written")
+			return nil, errInterrupted
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// Writes a baseline accepting every finding in the search result
+func (a *App) CreateBaseline(result *config.SearchResult, output, justification string, expires time.Time) error {
+	baseline := cmd.CreateBaseline(cmd.CollectFindings(result), justification, expires)
+
+	out, err := a.openOutput(output)
+	if err != nil {
+		return err
+	}
+	if err := baseline.Write(out); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+
+	if output != "-" {
+		fmt.Fprintf(a.Stdout, "Wrote %d suppression(s) expiring %s to %s\n",
+			len(baseline.Suppressions), expires.Format(config.DateLayout), output)
+	}
+	return nil
+}
+
+// Reads an expiry given as a date or as a number of days after now
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+		if days < 0 {
+			return time.Time{}, fmt.Errorf("invalid expiry %q: days must not be negative", value)
+		}
+		return now.AddDate(0, 0, days), nil
+	}
+	date, err := time.Parse(config.DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry %q, expected YYYY-MM-DD or a number of days", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+// Settings for checking a single certificate file
+type CheckOptions struct {
+	CertPath   string
+	Host       string
+	OCSP       bool
+	IssuerPath string
+	OCSPOpts   cmd.OCSPOptions
+	Settings   config.Settings
+}
+
+var checkCommand = &command{
+	name:    "check",
+	summary: "Check a certificate for FIPS 140-3 compliance, lint findings, revocation and host names",
+	usage:   "findcert check [flags] <certificate>",
+	examples: []string{
+		"findcert check server.pem",
+		"findcert check -host api.example.com server.pem",
+		"findcert check -ocsp -issuer ca.pem server.pem",
+		"findcert check -ocsp -ocsp-response server.ocsp -issuer ca.pem server.pem",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		configPath := fs.String("config", "", "Configuration file providing thresholds and disabled rules")
+		host := fs.String("host", "", "DNS name or IP address each leaf certificate must be valid for")
+		checkOCSP := fs.Bool("ocsp", false, "Check the certificate's revocation status over OCSP")
+		ocspURL := fs.String("ocsp-url", "", "OCSP responder URL overriding the certificate's AIA extension")
+		ocspResponse := fs.String("ocsp-response", "", "Pre-fetched DER encoded OCSP response to verify instead of querying a responder")
+		ocspCache := fs.String("ocsp-cache", cmd.DefaultOCSPCacheDir(), "Directory for cached OCSP responses (empty disables caching)")
+		issuerPath := fs.String("issuer", "", "Issuer certificate used to verify OCSP responses (defaults to the certificate bundle)")
+
+		return func(args []string) error {
+			if len(args) != 1 {
+				fs.Usage()
+				return errUsage
+			}
+			settings, err := config.LoadSettings(*configPath)
+			if err != nil {
+				return err
+			}
+			return a.Check(CheckOptions{
+				CertPath:   args[0],
+				Host:       *host,
+				OCSP:       *checkOCSP,
+				IssuerPath: *issuerPath,
+				OCSPOpts: cmd.OCSPOptions{
+					ResponderURL: *ocspURL,
+					ResponseFile: *ocspResponse,
+					CacheDir:     *ocspCache,
+				},
+				Settings: settings,
+			})
+		}
+	},
+}
+
+// Checks the first certificate in opts.CertPath and prints the result. The
+// check fails when a leaf of the bundle is not valid for opts.Host.
+func (a *App) Check(opts CheckOptions) error {
+	certs, err := cmd.LoadCertificates(opts.CertPath)
+	if err != nil {
+		return err
+	}
+	cert := certs[0]
+	result := cmd.NewChecker(opts.Settings).Check(cert)
+
+	var ocspResult *cmd.OCSPResult
+	if opts.OCSP {
+		ocspResult, err = checkRevocation(cert, opts.CertPath, opts.IssuerPath, opts.OCSPOpts)
+		if err != nil {
+			return err
+		}
+		cmd.ApplyOCSPResult(result, ocspResult)
+	}
+
+	cmd.PrintFIPSResult(a.Stdout, result, cert)
+	if ocspResult != nil {
+		cmd.PrintOCSPResult(a.Stdout, ocspResult)
+	}
+
+	if opts.Host != "" {
+		hostResults := cmd.CheckHostname(certs, opts.Host)
+		cmd.PrintHostnameResults(a.Stdout, opts.Host, hostResults)
+		for _, hostResult := range hostResults {
+			if !hostResult.Valid {
+				return errFailed
+			}
+		}
+	}
+	return nil
+}
+
+// Looks up the issuer of cert, from issuerPath or else the rest of the bundle, and queries OCSP
+func checkRevocation(cert *x509.Certificate, certPath, issuerPath string, opts cmd.OCSPOptions) (*cmd.OCSPResult, error) {
+	if issuerPath == "" {
+		issuerPath = certPath
+	}
+	candidates, err := cmd.LoadCertificates(issuerPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load issuer certificate: %w", err)
+	}
+	issuer := cmd.FindIssuer(cert, candidates)
+	if issuer == nil {
+		return nil, fmt.Errorf("issuer of %s not found, use -issuer to provide it", cert.Subject)
+	}
+	return cmd.CheckOCSP(cert, issuer, opts)
+}
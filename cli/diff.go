@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"org.gkh/findcert/cmd"
+)
+
+var diffCommand = &command{
+	name:    "diff",
+	summary: "Compare two scan results and show the certificates added, removed, renewed, moved or changed",
+	usage:   "findcert diff [flags] <old.json> <new.json>",
+	examples: []string{
+		"findcert diff last-week.json results.json",
+		"findcert diff -format json last-week.json results.json > changes.json",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		format := fs.String("format", "text", "Output format: text or json")
+
+		return func(args []string) error {
+			if len(args) != 2 || (*format != "text" && *format != "json") {
+				fs.Usage()
+				return errUsage
+			}
+			return a.Diff(args[0], args[1], *format == "json")
+		}
+	},
+}
+
+// Compares the results in oldPath with those in newPath
+func (a *App) Diff(oldPath, newPath string, asJSON bool) error {
+	previous, err := cmd.LoadSearchResult(oldPath)
+	if err != nil {
+		return err
+	}
+	current, err := cmd.LoadSearchResult(newPath)
+	if err != nil {
+		return err
+	}
+
+	diff := cmd.DiffResults(previous, current)
+	diff.Old.File = oldPath
+	diff.New.File = newPath
+
+	if !asJSON {
+		cmd.PrintDiff(a.Stdout, diff)
+		return nil
+	}
+	encoder := json.NewEncoder(a.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(diff); err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+	return nil
+}
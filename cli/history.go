@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/inventory"
+)
+
+var historyCommand = &command{
+	name:    "history",
+	summary: "Show when certificates recorded in an inventory database were first and last seen",
+	usage:   "findcert history [flags]",
+	examples: []string{
+		"findcert history -database inventory.db",
+		"findcert history -database inventory.db -non-compliant",
+		"findcert history -database inventory.db -scans -format json",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		database := fs.String("database", os.Getenv("FINDCERT_DATABASE"), "SQLite inventory written by scan -database")
+		nonCompliant := fs.Bool("non-compliant", false, "Only show certificates that were non-compliant in their last scan")
+		scans := fs.Bool("scans", false, "List the recorded scans instead of the certificates")
+		format := fs.String("format", "text", "Output format: text or json")
+
+		return func(args []string) error {
+			if len(args) != 0 || *database == "" || (*format != "text" && *format != "json") {
+				fs.Usage()
+				return errUsage
+			}
+			return a.History(*database, *scans, *nonCompliant, *format == "json")
+		}
+	},
+}
+
+// Prints the scans or the certificate history recorded in the database
+func (a *App) History(path string, scans, nonCompliant, asJSON bool) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("inventory does not exist: %s", path)
+	}
+	db, err := inventory.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var records any
+	if scans {
+		list, err := db.Scans()
+		if err != nil {
+			return err
+		}
+		records = list
+		if !asJSON {
+			printScans(a.Stdout, list)
+		}
+	} else {
+		list, err := db.Certificates()
+		if err != nil {
+			return err
+		}
+		if nonCompliant {
+			var kept []inventory.CertificateHistory
+			for _, cert := range list {
+				if !cert.Compliant {
+					kept = append(kept, cert)
+				}
+			}
+			list = kept
+		}
+		records = list
+		if !asJSON {
+			printCertificateHistory(a.Stdout, list, time.Now())
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(a.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	}
+	return nil
+}
+
+func printScans(w io.Writer, scans []inventory.Scan) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tHOST\tPATH\tSTARTED\tFILES\tFINDINGS")
+	for _, scan := range scans {
+		started := scan.StartedAt.Local().Format(time.RFC3339)
+		if scan.FinishedAt.IsZero() {
+			started += " (unfinished)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\n", scan.ID, scan.Host, scan.SearchPath, started, scan.TotalFiles, scan.Findings)
+	}
+	tw.Flush()
+}
+
+func printCertificateHistory(w io.Writer, certs []inventory.CertificateHistory, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBJECT\tEXPIRES\tFIRST SEEN\tLAST SEEN\tSCANS\tSTATUS\tPATHS")
+	for _, cert := range certs {
+		status := "compliant"
+		if cert.NonCompliantSince != nil {
+			status = fmt.Sprintf("non-compliant for %d days", int(now.Sub(*cert.NonCompliantSince).Hours()/24))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", cert.Subject, cert.NotAfter.Format(config.DateLayout),
+			cert.FirstSeen.Local().Format(config.DateLayout), cert.LastSeen.Local().Format(config.DateLayout),
+			cert.Scans, status, strings.Join(cert.Paths, ", "))
+	}
+	tw.Flush()
+}
+
+// Records a completed scan made on this host in the inventory at path
+func recordScan(path string, result *config.SearchResult) error {
+	db, err := inventory.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Record(hostname(), result)
+}
+
+// The name scans made here are recorded under
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "localhost"
+	}
+	return name
+}
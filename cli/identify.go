@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"org.gkh/findcert/pkg"
+)
+
+var identifyCommand = &command{
+	name:    "identify",
+	summary: "Identify the type of files from their contents, regardless of extension",
+	usage:   "findcert identify <file>...",
+	examples: []string{
+		"findcert identify /etc/pki/java/cacerts",
+		"findcert identify server.key truststore",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		return func(args []string) error {
+			if len(args) == 0 {
+				fs.Usage()
+				return errUsage
+			}
+
+			failed := false
+			for _, path := range args {
+				filetype, err := pkg.GetFileType(path)
+				if err != nil {
+					fmt.Fprintf(a.Stderr, "%s: %v\n", path, err)
+					failed = true
+					continue
+				}
+				fmt.Fprintf(a.Stdout, "%s: %s (%s, %s)\n", path, filetype.Description, filetype.MimeType, displayExtension(filetype.Extension))
+			}
+			if failed {
+				return errFailed
+			}
+			return nil
+		}
+	},
+}
+
+func displayExtension(ext string) string {
+	if ext == "" {
+		return "no extension"
+	}
+	return ext
+}
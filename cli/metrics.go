@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/report"
+	"org.gkh/findcert/scan"
+)
+
+var serveMetricsCommand = &command{
+	name:    "serve-metrics",
+	summary: "Rescan on an interval and serve the results as Prometheus metrics on /metrics",
+	usage:   "findcert serve-metrics [flags] [path]",
+	examples: []string{
+		"findcert serve-metrics -interval 6h /etc/ssl",
+		"findcert serve-metrics -listen 127.0.0.1:9791 -baseline findcert-baseline.yaml /etc/pki",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		flags := addScanFlags(fs)
+		listen := fs.String("listen", ":9791", "Address to serve /metrics on")
+		interval := fs.Duration("interval", time.Hour, "Time to wait after a scan before starting the next")
+
+		return func(args []string) error {
+			opts, err := flags.options(fs, args, func(*config.Settings, string) {})
+			if err != nil {
+				return err
+			}
+			if *interval <= 0 {
+				return fmt.Errorf("invalid interval %s, must be positive", *interval)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return a.ServeMetrics(ctx, opts, *listen, *interval)
+		}
+	},
+}
+
+// Scans opts.Path every interval and serves the metrics of the last
+// successful scan until ctx is done or the server fails. A scan running when
+// ctx is done is stopped and requests being served are given time to finish.
+func (a *App) ServeMetrics(ctx context.Context, opts ScanOptions, listen string, interval time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	metrics := newMetricsServer(opts)
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		for {
+			if err := metrics.rescan(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Fprintf(a.Stderr, "%s scan failed: %v\n", time.Now().Format(time.RFC3339), err)
+			} else if opts.Cache != nil {
+				if err := opts.Cache.Save(true); err != nil {
+					fmt.Fprintf(a.Stderr, "%s warning: %v\n", time.Now().Format(time.RFC3339), err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	// The scan is stopped before returning however the server ends
+	defer func() {
+		cancel()
+		<-scanned
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Addr: listen, Handler: mux}
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
+	fmt.Fprintf(a.Stdout, "Serving metrics for %s on %s/metrics, rescanning every %s\n", opts.Path, listen, interval)
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancelShutdown()
+	return server.Shutdown(shutdownCtx)
+}
+
+// How long requests being served are given to finish on shutdown
+const metricsShutdownTimeout = 5 * time.Second
+
+// Keeps the metrics of the last successful scan and counts the scans run
+type metricsServer struct {
+	opts ScanOptions
+
+	mu       sync.Mutex
+	metrics  []byte
+	scans    int
+	failures int
+	lastOK   bool
+}
+
+func newMetricsServer(opts ScanOptions) *metricsServer {
+	return &metricsServer{opts: opts}
+}
+
+// Runs a scan and replaces the metrics served when it succeeds
+func (s *metricsServer) rescan(ctx context.Context) error {
+	var result *config.SearchResult
+	scanner, err := scan.New(scan.Options{Settings: s.opts.Settings, Baseline: s.opts.Baseline, Cache: s.opts.Cache})
+	if err == nil {
+		result, err = scanner.Scan(ctx, s.opts.Path)
+	}
+
+	var buf bytes.Buffer
+	if err == nil {
+		err = report.WritePrometheus(&buf, result)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scans++
+	s.lastOK = err == nil
+	if err != nil {
+		s.failures++
+		return err
+	}
+	s.metrics = buf.Bytes()
+	return nil
+}
+
+func (s *metricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(s.metrics)
+	fmt.Fprintf(w, "# HELP findcert_scans_total Scans run since the server started.\n# TYPE findcert_scans_total counter\nfindcert_scans_total %d\n", s.scans)
+	fmt.Fprintf(w, "# HELP findcert_scan_failures_total Scans that failed since the server started.\n# TYPE findcert_scan_failures_total counter\nfindcert_scan_failures_total %d\n", s.failures)
+	fmt.Fprintf(w, "# HELP findcert_last_scan_success Whether the last scan succeeded (1) or not (0).\n# TYPE findcert_last_scan_success gauge\nfindcert_last_scan_success %d\n", boolMetric(s.lastOK))
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/pkg"
+)
+
+var noextCommand = &command{
+	name:    "noext",
+	summary: "List non-executable files without an extension in a directory and identify them",
+	usage:   "findcert noext [directory]",
+	examples: []string{
+		"findcert noext /etc/pki/java",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		return func(args []string) error {
+			if len(args) > 1 {
+				fs.Usage()
+				return errUsage
+			}
+			dir := "."
+			if len(args) == 1 {
+				dir = args[0]
+			}
+			return a.NoExt(dir)
+		}
+	},
+}
+
+// Lists the files without an extension in dir and the type detected for each
+func (a *App) NoExt(dir string) error {
+	fmt.Fprintln(a.Stdout, "Listing files with no extension...")
+	results, err := cmd.ListNoExt(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.Stdout, "Found %d\n", len(results))
+	for _, file := range results {
+		fmt.Fprintln(a.Stdout, file.Path)
+		filetype, err := pkg.GetFileType(file.Path)
+		if err != nil {
+			fmt.Fprintf(a.Stdout, "  - %v\n", err)
+			continue
+		}
+		fmt.Fprintf(a.Stdout, "  - %s, %s\n", filetype.Extension, filetype.Description)
+	}
+	return nil
+}
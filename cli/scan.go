@@ -0,0 +1,548 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"org.gkh/findcert/cache"
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/notify"
+	"org.gkh/findcert/report"
+	"org.gkh/findcert/scan"
+	"org.gkh/findcert/ui"
+)
+
+// Settings for a certificate search
+type ScanOptions struct {
+	Path     string
+	Settings config.Settings
+	Format   *report.Format
+	// Accepted findings, nil for none
+	Baseline *config.Baseline
+	// How long the scan may run before it stops with partial results, 0 for no limit
+	Timeout time.Duration
+	// Where to save checkpoints of the scan's progress, empty for none
+	Checkpoint         string
+	CheckpointInterval time.Duration
+	// The checkpoint to continue from, nil to start afresh
+	Resume *scan.Checkpoint
+	// Where unchanged files are taken from, nil for none
+	Cache *cache.Cache
+}
+
+var scanCommand = &command{
+	name:    "scan",
+	summary: "Search a directory tree for certificates, key stores and CRLs and check their compliance",
+	usage:   "findcert scan [flags] [path]",
+	examples: []string{
+		"findcert scan /etc/ssl",
+		"findcert scan -output /tmp/ssl.json -hosts api.example.com,www.example.com /etc/ssl",
+		"findcert scan -allowed-owners root,nginx -fail-on error /etc/nginx",
+		"findcert scan -config ./findcert.yaml -exclude 'node_modules,*.bak' .",
+		"findcert scan -baseline findcert-baseline.yaml -fail-on warning /etc/ssl",
+		"findcert scan -format csv -output inventory.csv /etc/pki",
+		"findcert scan -format sarif -output findcert.sarif -fail-on error .",
+		"findcert scan -format html -output audit.html /etc/pki",
+		"findcert scan -format cyclonedx -output cbom.cdx.json /",
+		"findcert scan -format ndjson -output - / | jq 'select(.type == \"finding\")'",
+		"findcert scan -database /var/lib/findcert/inventory.db /etc/ssl",
+		"findcert scan -timeout 10m /",
+		"findcert scan -checkpoint nas.checkpoint.json /mnt/nas",
+		"findcert scan -resume nas.checkpoint.json /mnt/nas",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		flags := addScanFlags(fs)
+		outputFile := fs.String("output", "", "Output file path, - for standard output (default results.<format extension>)")
+		format := fs.String("format", "json", "Output format: "+strings.Join(report.Names(), ", "))
+		database := fs.String("database", "", "SQLite inventory to record the scan in as well, created if missing")
+		failOn := fs.String("fail-on", "none", "Exit with status 1 when a finding has this severity or higher: none, notice, warning or error")
+		timeout := fs.Duration("timeout", 0, "Stop the scan after this long and write the results found so far, marked incomplete (default no limit)")
+		checkpoint := fs.String("checkpoint", "", "Save the directories finished and their findings to this file while scanning, to resume from with -resume")
+		checkpointInterval := fs.Duration("checkpoint-interval", time.Minute, "How often to save the checkpoint")
+		resume := fs.String("resume", "", "Continue the scan saved in this checkpoint file, without walking its finished directories again")
+
+		return func(args []string) error {
+			opts, err := flags.options(fs, args, func(settings *config.Settings, name string) {
+				switch name {
+				case "database":
+					settings.Output.Database = *database
+				case "output":
+					settings.Output.File = *outputFile
+				case "format":
+					settings.Output.Format = *format
+				case "fail-on":
+					settings.Policy.FailOn = *failOn
+				}
+			})
+			if err != nil {
+				return err
+			}
+			if *timeout < 0 {
+				return fmt.Errorf("invalid timeout %s", *timeout)
+			}
+			opts.Timeout = *timeout
+			if *checkpointInterval <= 0 {
+				return fmt.Errorf("invalid checkpoint interval %s", *checkpointInterval)
+			}
+			opts.Checkpoint, opts.CheckpointInterval = *checkpoint, *checkpointInterval
+			if *resume != "" {
+				if opts.Resume, err = scan.LoadCheckpoint(*resume); err != nil {
+					return err
+				}
+				if opts.Checkpoint == "" {
+					opts.Checkpoint = *resume
+				}
+			}
+			if opts.Format, err = report.Lookup(opts.Settings.Output.Format); err != nil {
+				return err
+			}
+			if opts.Settings.Output.File == "" {
+				opts.Settings.Output.File = opts.Format.DefaultFile()
+			}
+			return a.Scan(opts)
+		}
+	},
+}
+
+// The flags that choose what a scan searches and which policy it applies,
+// shared by the commands that scan
+type scanFlags struct {
+	configPath    *string
+	searchPath    *string
+	extensions    *string
+	exclude       *string
+	hosts         *string
+	hostsFile     *string
+	allowedOwners *string
+	baseline      *string
+	cache         *string
+	cacheHash     *bool
+}
+
+func addScanFlags(fs *flag.FlagSet) *scanFlags {
+	f := addSettingsFlags(fs)
+	f.searchPath = fs.String("path", ".", "Directory path to search (or pass it as an argument)")
+	f.hosts = fs.String("hosts", "", "Comma separated host names that must be covered by a certificate found in the scan")
+	f.hostsFile = fs.String("hosts-file", "", "File listing host names, one per line, that must be covered by a certificate found in the scan")
+	f.cache = fs.String("cache", "", "Cache file of the contents of files read, so that rescans only read the files that changed")
+	f.cacheHash = fs.Bool("cache-hash", false, "Also compare the SHA-256 of every file with the cache, for file systems whose modification times can not be trusted")
+	return f
+}
+
+// The flags choosing which files are checked and how, for commands that
+// inspect files without scanning a single tree
+func addSettingsFlags(fs *flag.FlagSet) *scanFlags {
+	flags := addSelectionFlags(fs)
+	flags.baseline = fs.String("baseline", "", "Baseline file of accepted findings to leave out of the results and the fail-on check")
+	return flags
+}
+
+// Adds the flags choosing what a search looks at and reports, without a
+// baseline
+func addSelectionFlags(fs *flag.FlagSet) *scanFlags {
+	return &scanFlags{
+		configPath:    fs.String("config", "", "Configuration file (default $XDG_CONFIG_HOME/findcert/findcert.yaml, then /etc/findcert/findcert.yaml)"),
+		extensions:    fs.String("extensions", "", "Comma separated file extensions to search for (default from the configuration)"),
+		exclude:       fs.String("exclude", "", "Comma separated glob patterns of files and directories to skip"),
+		allowedOwners: fs.String("allowed-owners", "", "Comma separated users (names or UIDs) allowed to own private keys and key stores"),
+	}
+}
+
+// Resolves the search path from the flags or the single argument, loads the
+// configuration and the baseline, and applies the flags given on the command
+// line. Flags of the command itself are handed to override.
+func (f *scanFlags) options(fs *flag.FlagSet, args []string, override func(settings *config.Settings, name string)) (ScanOptions, error) {
+	if len(args) > 1 {
+		fs.Usage()
+		return ScanOptions{}, errUsage
+	}
+	path := *f.searchPath
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ScanOptions{}, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return ScanOptions{}, fmt.Errorf("path does not exist: %s", absPath)
+	}
+
+	settings, baseline, err := f.settings(fs, override)
+	if err != nil {
+		return ScanOptions{}, err
+	}
+	opts := ScanOptions{Path: absPath, Settings: settings, Baseline: baseline}
+	if settings.Cache.File != "" {
+		if opts.Cache, err = cache.Open(settings.Cache.File, settings.Cache.Hash); err != nil {
+			return ScanOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+// Loads the configuration and the baseline and applies the flags given on
+// the command line
+func (f *scanFlags) settings(fs *flag.FlagSet, override func(settings *config.Settings, name string)) (config.Settings, *config.Baseline, error) {
+	settings, err := f.searchSettings(fs, override)
+	if err != nil {
+		return config.Settings{}, nil, err
+	}
+
+	var baseline *config.Baseline
+	if settings.Policy.Baseline != "" {
+		if baseline, err = config.LoadBaseline(settings.Policy.Baseline); err != nil {
+			return config.Settings{}, nil, err
+		}
+	}
+	return settings, baseline, nil
+}
+
+// Loads the configuration and applies the flags given on the command line,
+// leaving the baseline it names unread
+func (f *scanFlags) searchSettings(fs *flag.FlagSet, override func(settings *config.Settings, name string)) (config.Settings, error) {
+	settings, err := config.LoadSettings(*f.configPath)
+	if err != nil {
+		return config.Settings{}, err
+	}
+
+	// Flags given on the command line override the file and environment
+	var flagErr error
+	fs.Visit(func(given *flag.Flag) {
+		switch given.Name {
+		case "extensions":
+			settings.Extensions = config.SplitList(*f.extensions)
+		case "exclude":
+			settings.Exclude = config.SplitList(*f.exclude)
+		case "hosts":
+			settings.Policy.Hosts = config.SplitList(*f.hosts)
+		case "hosts-file":
+			fromFile, err := readList(*f.hostsFile)
+			if err != nil {
+				flagErr = fmt.Errorf("failed to read hosts file: %w", err)
+			}
+			settings.Policy.Hosts = append(settings.Policy.Hosts, fromFile...)
+		case "allowed-owners":
+			settings.Policy.AllowedOwners = config.SplitList(*f.allowedOwners)
+		case "baseline":
+			settings.Policy.Baseline = *f.baseline
+		case "cache":
+			settings.Cache.File = *f.cache
+		case "cache-hash":
+			settings.Cache.Hash = *f.cacheHash
+		default:
+			override(&settings, given.Name)
+		}
+	})
+	if flagErr != nil {
+		return config.Settings{}, flagErr
+	}
+	if err := settings.Validate(); err != nil {
+		return config.Settings{}, err
+	}
+	return settings, nil
+}
+
+// Searches opts.Path, prints the findings and writes the results file. The
+// scan fails once it is complete if a finding reaches the fail_on severity.
+// An interrupted or timed out scan still writes the results found so far,
+// marked incomplete, and fails.
+func (a *App) Scan(opts ScanOptions) error {
+	if opts.Format.NewStream != nil {
+		return a.streamScan(opts)
+	}
+
+	console := a.console(opts.Settings)
+	fmt.Fprintf(console, "%sCertificate File Finder%s\n", ui.ColorYellow, ui.ColorReset)
+
+	settings := opts.Settings
+	failing := 0
+	var findings []config.LocatedFinding
+	scanner, err := scan.New(opts.scannerOptions(scan.Options{
+		Settings: settings,
+		Baseline: opts.Baseline,
+		OnFinding: func(finding config.LocatedFinding) error {
+			if config.SeverityAtLeast(finding.Severity, settings.Policy.FailOn) {
+				failing++
+			}
+			if len(settings.Notify) > 0 {
+				findings = append(findings, finding)
+			}
+			return nil
+		},
+	}))
+	if err != nil {
+		return err
+	}
+
+	printResume(console, opts.Resume)
+	ctx, cancel := scanContext(opts.Timeout)
+	defer cancel()
+	spinner := startSpinner(console)
+	searchResult, scanErr := scanner.Scan(ctx, opts.Path)
+	if spinner != nil {
+		spinner.Stop()
+	}
+	if searchResult == nil {
+		return scanErr
+	}
+	a.saveCache(opts, searchResult)
+	a.warnExpired(opts.Baseline, searchResult.SearchTime)
+
+	printSearchResult(console, searchResult)
+
+	out, err := a.openOutput(settings.Output.File)
+	if err != nil {
+		return err
+	}
+	if err := opts.Format.Write(out, searchResult); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s report: %w", opts.Format.Name, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s report: %w", opts.Format.Name, err)
+	}
+
+	// An incomplete scan is not recorded, as the certificates it never
+	// reached would look removed
+	if settings.Output.Database != "" && !searchResult.Incomplete {
+		if err := recordScan(settings.Output.Database, searchResult); err != nil {
+			return err
+		}
+	}
+
+	printSummary(console, searchResult, settings.Output.File)
+	a.notify(settings, searchResult, findings)
+	if scanErr != nil {
+		return a.stopped(scanErr, opts)
+	}
+	opts.removeCheckpoint()
+	return a.failOn(failing, settings.Policy.FailOn)
+}
+
+// Tells the configured commands and webhooks about the findings of a scan. A
+// notification that fails is reported without failing the scan.
+func (a *App) notify(settings config.Settings, result *config.SearchResult, findings []config.LocatedFinding) {
+	if len(settings.Notify) == 0 {
+		return
+	}
+	report := notify.NewReport(hostname(), result, findings)
+	notifier := &notify.Notifier{Client: &http.Client{Timeout: webhookTimeout}, Stdout: a.Stderr, Stderr: a.Stderr}
+	for i := range settings.Notify {
+		notification := &settings.Notify[i]
+		// The scan's context may have been interrupted, the notification should still go out
+		if _, err := notifier.Send(context.Background(), notification, report); err != nil {
+			fmt.Fprintf(a.Stderr, "Warning: notification to %s failed: %v\n", notification.Target(), err)
+		}
+	}
+}
+
+// Adds the checkpoints, the checkpoint to resume from and the cache of the
+// scan to the scanner's options
+func (opts ScanOptions) scannerOptions(scanOpts scan.Options) scan.Options {
+	scanOpts.Resume = opts.Resume
+	scanOpts.Cache = opts.Cache
+	scanOpts.CheckpointPath = opts.Checkpoint
+	scanOpts.CheckpointInterval = opts.CheckpointInterval
+	return scanOpts
+}
+
+// Saves the cache after a scan. A complete scan that was not resumed has read
+// every file, so the files it did not find are forgotten. The cache only
+// saves time, so failing to save it is not an error.
+func (a *App) saveCache(opts ScanOptions, result *config.SearchResult) {
+	if opts.Cache == nil {
+		return
+	}
+	if err := opts.Cache.Save(!result.Incomplete && opts.Resume == nil); err != nil {
+		fmt.Fprintf(a.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// Deletes the checkpoint of a scan that has finished, as there is nothing left to resume
+func (opts ScanOptions) removeCheckpoint() {
+	if opts.Checkpoint != "" {
+		os.Remove(opts.Checkpoint)
+	}
+}
+
+func printResume(console io.Writer, checkpoint *scan.Checkpoint) {
+	if checkpoint == nil {
+		return
+	}
+	fmt.Fprintf(console, "Resuming from the checkpoint of %s: %d directories and %d files already scanned\n",
+		checkpoint.Updated.Format(time.RFC3339), len(checkpoint.Done), len(checkpoint.Files))
+}
+
+// The context a scan runs in, done on SIGINT or SIGTERM or once timeout has
+// passed. Only the first signal stops the scan cleanly; once it has arrived a
+// second one ends the process as usual.
+func scanContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := stop
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancel = func() {
+			cancelTimeout()
+			stop()
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, cancel
+}
+
+// Explains why a scan stopped early and returns the error to exit with
+func (a *App) stopped(err error, opts ScanOptions) error {
+	if err != context.Canceled && err != context.DeadlineExceeded {
+		// Saving the checkpoint failed as well
+		fmt.Fprintf(a.Stderr, "Error: %v\n", err)
+	} else if opts.Checkpoint != "" {
+		fmt.Fprintf(a.Stderr, "Resume the scan with -resume %s\n", opts.Checkpoint)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintf(a.Stderr, "Scan timed out after %s, the results are incomplete\n", opts.Timeout)
+		return errFailed
+	}
+	fmt.Fprintln(a.Stderr, "Scan interrupted, the results are incomplete")
+	return errInterrupted
+}
+
+// Where progress and the human readable results go: nowhere when the report
+// itself is written to standard output
+func (a *App) console(settings config.Settings) io.Writer {
+	if settings.Output.File == "-" {
+		return io.Discard
+	}
+	return a.Stdout
+}
+
+func startSpinner(console io.Writer) *ui.Spinner {
+	if !ui.IsTerminal(console) {
+		return nil
+	}
+	spinner := ui.NewSpinner()
+	spinner.Start("Searching for certificate files...")
+	return spinner
+}
+
+// Opens the report file, or standard output for "-"
+func (a *App) openOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{a.Stdout}, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return file, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func printSummary(w io.Writer, result *config.SearchResult, outputFile string) {
+	fmt.Fprintf(w, "%sSummary:%s\n", ui.ColorYellow, ui.ColorReset)
+	fmt.Fprintf(w, "Total certificate files found: %d\n", result.TotalFiles)
+	if result.Cache != nil {
+		fmt.Fprintf(w, "Unchanged files taken from the cache: %d, files read: %d\n", result.Cache.Hits, result.Cache.Misses)
+	}
+	fmt.Fprintf(w, "Results have been saved to %s\n", outputFile)
+}
+
+// Tells about accepted findings whose suppression has run out, as they are reported again
+func (a *App) warnExpired(baseline *config.Baseline, now time.Time) {
+	for _, s := range baseline.Expired(now) {
+		target := s.Fingerprint
+		if target == "" {
+			target = s.Path
+		}
+		fmt.Fprintf(a.Stderr, "Suppression of %s for %s expired on %s\n", s.RuleID, target, s.Expires)
+	}
+}
+
+// Fails the scan if any finding reached the fail_on severity
+func (a *App) failOn(failing int, severity string) error {
+	if failing > 0 {
+		fmt.Fprintf(a.Stderr, "%d finding(s) at or above severity %s\n", failing, severity)
+		return errFailed
+	}
+	return nil
+}
+
+// Prints the files found for each extension, CRL status and host coverage
+func printSearchResult(w io.Writer, searchResult *config.SearchResult) {
+	for _, result := range searchResult.Results {
+		fmt.Fprintf(w, "%sFiles with extension %s:%s\n", ui.ColorGreen, result.Type, ui.ColorReset)
+		if len(result.Files) == 0 {
+			fmt.Fprintln(w, "No files found")
+		} else {
+			for _, file := range result.Files {
+				displayPath := file.Path
+				if len(file.Path) > 50 {
+					displayPath = file.Path[:23] + "..." + file.Path[len(file.Path)-24:]
+				}
+				fmt.Fprintf(w, "%s (Size: %d bytes, Modified: %s)\n",
+					displayPath, file.Size, file.ModifiedTime.Format(time.RFC3339))
+				for _, issue := range file.PermissionIssues {
+					fmt.Fprintf(w, "  - [%s] %s: %s\n", issue.Severity, issue.RuleID, issue.Message)
+				}
+				for _, cert := range file.Certificates {
+					if cert.Revoked {
+						fmt.Fprintf(w, "  - %sREVOKED%s: %s (serial %s)\n", ui.ColorYellow, ui.ColorReset, cert.Subject, cert.SerialNumber)
+					}
+					for _, lint := range cert.Lints {
+						fmt.Fprintf(w, "  - [%s] %s: %s\n", lint.Severity, lint.RuleID, lint.Message)
+					}
+				}
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(searchResult.CRLs) > 0 {
+		fmt.Fprintf(w, "%sCertificate revocation lists:%s\n", ui.ColorGreen, ui.ColorReset)
+		for _, crl := range searchResult.CRLs {
+			switch {
+			case crl.Error != "":
+				fmt.Fprintf(w, "%s: %s\n", crl.Path, crl.Error)
+			case !crl.Verified:
+				fmt.Fprintf(w, "%s: signature could not be verified against any certificate found\n", crl.Path)
+			case crl.Stale:
+				fmt.Fprintf(w, "%s: stale, next update was due %s\n", crl.Path, crl.NextUpdate.Format(time.RFC3339))
+			default:
+				fmt.Fprintf(w, "%s: %d revoked, next update %s\n", crl.Path, crl.RevokedCount, crl.NextUpdate.Format(time.RFC3339))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(searchResult.Hosts) > 0 {
+		fmt.Fprintf(w, "%sHost name coverage:%s\n", ui.ColorGreen, ui.ColorReset)
+		for _, coverage := range searchResult.Hosts {
+			if len(coverage.Paths) == 0 {
+				fmt.Fprintf(w, "%s: %sNO COVERING CERTIFICATE%s\n", coverage.Host, ui.ColorYellow, ui.ColorReset)
+			} else {
+				fmt.Fprintf(w, "%s: covered by %d file(s)\n", coverage.Host, len(coverage.Paths))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
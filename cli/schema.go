@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"flag"
+
+	"org.gkh/findcert/schema"
+)
+
+var schemaCommand = &command{
+	name:    "schema",
+	summary: "Print the JSON Schema of the results written by scan -format json",
+	usage:   "findcert schema",
+	examples: []string{
+		"findcert schema > results.schema.json",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		return func(args []string) error {
+			if len(args) != 0 {
+				fs.Usage()
+				return errUsage
+			}
+			_, err := a.Stdout.Write(schema.Results)
+			return err
+		}
+	},
+}
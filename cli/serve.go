@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/server"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "Serve a REST API for scan jobs, certificate checks and file identification",
+	usage:   "findcert serve [flags]",
+	examples: []string{
+		"findcert serve -roots /etc/ssl,/etc/pki",
+		"findcert serve -listen :8080 -max-concurrent 4 -config /etc/findcert/findcert.yaml",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		configPath := fs.String("config", "", "Configuration file providing the scan defaults, thresholds and policy")
+		listen := fs.String("listen", "127.0.0.1:8080", "Address to serve the API on")
+		roots := fs.String("roots", "", "Comma separated directories scans are allowed in (default any path)")
+		maxConcurrent := fs.Int("max-concurrent", 2, "Scans run at the same time; others wait in the queue")
+		maxJobs := fs.Int("max-jobs", 100, "Scans kept for polling; the oldest finished ones are dropped first")
+
+		return func(args []string) error {
+			if len(args) != 0 || *maxConcurrent < 1 || *maxJobs < 1 {
+				fs.Usage()
+				return errUsage
+			}
+			settings, err := config.LoadSettings(*configPath)
+			if err != nil {
+				return err
+			}
+			opts := server.Options{
+				Settings:      settings,
+				Roots:         config.SplitList(*roots),
+				MaxConcurrent: *maxConcurrent,
+				MaxJobs:       *maxJobs,
+			}
+			if settings.Policy.Baseline != "" {
+				if opts.Baseline, err = config.LoadBaseline(settings.Policy.Baseline); err != nil {
+					return err
+				}
+			}
+			return a.Serve(opts, *listen)
+		}
+	},
+}
+
+// Serves the API until the process is interrupted, then cancels the scans still running
+func (a *App) Serve(opts server.Options, listen string) error {
+	api := server.New(opts)
+	defer api.Close()
+	httpServer := &http.Server{Addr: listen, Handler: api, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(a.Stdout, "Serving the findcert API on http://%s, documentation at /docs\n", listen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
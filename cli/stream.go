@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/inventory"
+	"org.gkh/findcert/report"
+	"org.gkh/findcert/scan"
+	"org.gkh/findcert/ui"
+)
+
+// Scans opts.Path and hands every file and finding to the output format as
+// soon as it has been inspected. Only the parsed certificates needed for the
+// CRL check and the host coverage are kept until the end; revocations, CRL
+// problems and uncovered hosts are reported after the last file.
+func (a *App) streamScan(opts ScanOptions) error {
+	settings := opts.Settings
+	console := a.console(settings)
+	fmt.Fprintf(console, "%sCertificate File Finder%s\n", ui.ColorYellow, ui.ColorReset)
+
+	out, err := a.openOutput(settings.Output.File)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	formatted := opts.Format.NewStream(out)
+	stream := formatted
+
+	start := time.Now()
+	if settings.Output.Database != "" {
+		db, err := inventory.Open(settings.Output.Database)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		recorder, err := db.BeginScan(hostname(), opts.Path, start)
+		if err != nil {
+			return err
+		}
+		// Only a scan that reaches Finish is committed
+		defer recorder.Abort()
+		stream = teeStream{stream, recorder}
+	}
+
+	a.warnExpired(opts.Baseline, start)
+
+	failing := 0
+	var findings []config.LocatedFinding
+	scanner, err := scan.New(opts.scannerOptions(scan.Options{
+		Settings:     settings,
+		Baseline:     opts.Baseline,
+		DiscardFiles: true,
+		OnFile: func(extension string, file *config.FileInfo) error {
+			if err := stream.File(extension, file); err != nil {
+				return fmt.Errorf("failed to write %s record: %w", opts.Format.Name, err)
+			}
+			return nil
+		},
+		OnFinding: func(finding config.LocatedFinding) error {
+			if config.SeverityAtLeast(finding.Severity, settings.Policy.FailOn) {
+				failing++
+			}
+			if len(settings.Notify) > 0 {
+				findings = append(findings, finding)
+			}
+			if err := stream.Finding(finding); err != nil {
+				return fmt.Errorf("failed to write %s record: %w", opts.Format.Name, err)
+			}
+			return nil
+		},
+	}))
+	if err != nil {
+		return err
+	}
+
+	printResume(console, opts.Resume)
+	ctx, cancel := scanContext(opts.Timeout)
+	defer cancel()
+	spinner := startSpinner(console)
+	result, err := scanner.Scan(ctx, opts.Path)
+	if spinner != nil {
+		spinner.Stop()
+	}
+	if result == nil {
+		return err
+	}
+	a.saveCache(opts, result)
+
+	if result.Incomplete {
+		// Leave the inventory out so the scan is rolled back
+		stream = formatted
+	}
+	if err := stream.Finish(result); err != nil {
+		return fmt.Errorf("failed to write %s summary: %w", opts.Format.Name, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write %s report: %w", opts.Format.Name, err)
+	}
+
+	printSummary(console, result, settings.Output.File)
+	a.notify(settings, result, findings)
+	if err != nil {
+		return a.stopped(err, opts)
+	}
+	opts.removeCheckpoint()
+	return a.failOn(failing, settings.Policy.FailOn)
+}
+
+// Hands every record to each of the streams in turn
+type teeStream []report.Stream
+
+func (t teeStream) File(extension string, file *config.FileInfo) error {
+	for _, stream := range t {
+		if err := stream.File(extension, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeStream) Finding(finding config.LocatedFinding) error {
+	for _, stream := range t {
+		if err := stream.Finding(finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeStream) Finish(result *config.SearchResult) error {
+	for _, stream := range t {
+		if err := stream.Finish(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
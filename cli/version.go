@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+)
+
+var versionCommand = &command{
+	name:    "version",
+	summary: "Show version information",
+	usage:   "findcert version",
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		return func(args []string) error {
+			version := a.Version
+			if a.GitSHA != "" {
+				version += "+" + a.GitSHA
+			}
+			fmt.Fprintf(a.Stdout, "%s (%s on %s/%s; %s)\n", version, runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.Compiler)
+			return nil
+		}
+	},
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/notify"
+	"org.gkh/findcert/watch"
+)
+
+var watchCommand = &command{
+	name:    "watch",
+	summary: "Follow directories for certificates being added, replaced or removed and check each change",
+	usage:   "findcert watch [flags] <directory>...",
+	examples: []string{
+		"findcert watch /etc/ssl /opt/app/certs",
+		"findcert watch /etc/ssl | jq 'select(.findings != null)'",
+		"findcert watch -webhook http://127.0.0.1:9000/findcert /etc/ssl",
+		"findcert watch -exec 'logger -t findcert' /etc/pki",
+		"findcert watch -poll -interval 1m /mnt/nfs/certs",
+	},
+	setup: func(a *App, fs *flag.FlagSet) func(args []string) error {
+		flags := addSettingsFlags(fs)
+		poll := fs.Bool("poll", false, "Poll for changes instead of using file system notifications, e.g. for network file systems")
+		interval := fs.Duration("interval", 10*time.Second, "How often to poll, also when notifications are unavailable")
+		webhook := fs.String("webhook", "", "URL to POST every event to as JSON")
+		execHook := fs.String("exec", "", "Command to run through the shell for every event, with the event as JSON on standard input")
+
+		return func(args []string) error {
+			if len(args) == 0 {
+				fs.Usage()
+				return errUsage
+			}
+			if *interval <= 0 {
+				return fmt.Errorf("invalid interval %s, must be positive", *interval)
+			}
+			for i, dir := range args {
+				abs, err := filepath.Abs(dir)
+				if err != nil {
+					return fmt.Errorf("failed to resolve path: %w", err)
+				}
+				if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+					return fmt.Errorf("not a directory: %s", abs)
+				}
+				args[i] = abs
+			}
+			settings, baseline, err := flags.settings(fs, func(*config.Settings, string) {})
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return a.Watch(ctx, WatchOptions{
+				Dirs:     args,
+				Settings: settings,
+				Baseline: baseline,
+				Poll:     *poll,
+				Interval: *interval,
+				Webhook:  *webhook,
+				Exec:     *execHook,
+			})
+		}
+	},
+}
+
+// Settings for following directories
+type WatchOptions struct {
+	Dirs     []string
+	Settings config.Settings
+	// Accepted findings, nil for none
+	Baseline *config.Baseline
+	Poll     bool
+	Interval time.Duration
+	// URL every event is POSTed to, none when empty
+	Webhook string
+	// Shell command run for every event, none when empty
+	Exec string
+}
+
+// How long a webhook may take to accept an event
+const webhookTimeout = 10 * time.Second
+
+// Writes every change below opts.Dirs to standard output as a line of JSON
+// and hands it to the webhook and command until ctx is done. A webhook or
+// command that fails is reported and the watch goes on.
+func (a *App) Watch(ctx context.Context, opts WatchOptions) error {
+	encoder := json.NewEncoder(a.Stdout)
+	client := &http.Client{Timeout: webhookTimeout}
+
+	watcher, err := watch.New(watch.Options{
+		Settings:     opts.Settings,
+		Baseline:     opts.Baseline,
+		Poll:         opts.Poll,
+		PollInterval: opts.Interval,
+		OnEvent: func(event watch.Event) error {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+			if opts.Webhook == "" && opts.Exec == "" {
+				return nil
+			}
+			var payload bytes.Buffer
+			if err := json.NewEncoder(&payload).Encode(event); err != nil {
+				return err
+			}
+			if opts.Webhook != "" {
+				if err := notify.Post(ctx, client, opts.Webhook, payload.Bytes()); err != nil {
+					fmt.Fprintf(a.Stderr, "Warning: %v\n", err)
+				}
+			}
+			if opts.Exec != "" {
+				env := []string{"FINDCERT_EVENT=" + event.Type, "FINDCERT_PATH=" + event.Path}
+				if err := notify.Exec(ctx, opts.Exec, payload.Bytes(), env, a.Stderr, a.Stderr); err != nil {
+					fmt.Fprintf(a.Stderr, "Warning: %v\n", err)
+				}
+			}
+			return nil
+		},
+		OnFallback: func(err error) {
+			fmt.Fprintf(a.Stderr, "File system notifications are unavailable (%v), polling every %s\n", err, opts.Interval)
+		},
+		OnReady: func() {
+			fmt.Fprintf(a.Stderr, "Watching %s for changes\n", strings.Join(opts.Dirs, ", "))
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return watcher.Run(ctx, opts.Dirs)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Moves the findings of the file the baseline accepts into file.Suppressed.
+// A certificate whose violations were all accepted counts as compliant. Findings
+// already suppressed are not added again, so the file can be suppressed again
+// after new findings were added to it.
+func SuppressFile(file *config.FileInfo, baseline *config.Baseline, now time.Time) {
+	if baseline == nil {
+		return
+	}
+
+	suppress := func(finding config.LocatedFinding) bool {
+		s := baseline.Suppression(finding, now)
+		if s == nil {
+			return false
+		}
+		if isSuppressed(file.Suppressed, finding) {
+			return true
+		}
+		file.Suppressed = append(file.Suppressed, config.SuppressedFinding{
+			LocatedFinding: finding,
+			Justification:  s.Justification,
+			Expires:        s.Expires,
+		})
+		return true
+	}
+
+	for i := range file.Certificates {
+		cert := &file.Certificates[i]
+		located := func(finding config.Finding) config.LocatedFinding {
+			return config.LocatedFinding{Finding: finding, Path: file.Path, Subject: cert.Subject, Fingerprint: cert.Fingerprint, Lines: cert.Lines}
+		}
+
+		violations := len(cert.Violations)
+		cert.Violations = keepFindings(cert.Violations, func(f config.Finding) bool { return !suppress(located(f)) })
+		cert.Lints = keepFindings(cert.Lints, func(f config.Finding) bool { return !suppress(located(f)) })
+		if len(cert.Violations) < violations && len(cert.Violations) == 0 {
+			cert.Compliant = true
+		}
+	}
+
+	file.PermissionIssues = keepFindings(file.PermissionIssues, func(f config.Finding) bool {
+		return !suppress(config.LocatedFinding{Finding: f, Path: file.Path})
+	})
+
+	// Private keys are a property of the file rather than a stored finding
+	for _, finding := range FileFindings(file) {
+		if finding.RuleID == "private-key-file" {
+			suppress(finding)
+		}
+	}
+}
+
+// Applies the baseline to every file of the result and records the CRL and
+// host findings it accepts in result.Suppressed
+func ApplyBaseline(result *config.SearchResult, baseline *config.Baseline, now time.Time) {
+	if baseline == nil {
+		return
+	}
+	for i := range result.Results {
+		for j := range result.Results[i].Files {
+			SuppressFile(&result.Results[i].Files[j], baseline, now)
+		}
+	}
+	for _, finding := range append(CRLFindings(result.CRLs), HostFindings(result.Hosts)...) {
+		if s := baseline.Suppression(finding, now); s != nil {
+			result.Suppressed = append(result.Suppressed, config.SuppressedFinding{
+				LocatedFinding: finding,
+				Justification:  s.Justification,
+				Expires:        s.Expires,
+			})
+		}
+	}
+}
+
+// Builds a baseline accepting every finding, each with the same justification and expiry
+func CreateBaseline(findings []config.LocatedFinding, justification string, expires time.Time) *config.Baseline {
+	baseline := &config.Baseline{Suppressions: []config.Suppression{}}
+	seen := make(map[config.Suppression]bool)
+	for _, finding := range findings {
+		s := config.Suppression{
+			RuleID:        finding.RuleID,
+			Fingerprint:   finding.Fingerprint,
+			Subject:       finding.Subject,
+			Justification: justification,
+			Expires:       expires.Format(config.DateLayout),
+		}
+		if s.Fingerprint == "" {
+			s.Path = finding.Path
+		}
+		if s.Fingerprint == "" && s.Path == "" {
+			// Uncovered hosts are fixed in the policy, not suppressed
+			continue
+		}
+		if !seen[s] {
+			seen[s] = true
+			baseline.Suppressions = append(baseline.Suppressions, s)
+		}
+	}
+	return baseline
+}
+
+func keepFindings(findings []config.Finding, keep func(config.Finding) bool) []config.Finding {
+	var kept []config.Finding
+	for _, finding := range findings {
+		if keep(finding) {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
+
+// Whether the finding is among the suppressed ones
+func isSuppressed(suppressed []config.SuppressedFinding, finding config.LocatedFinding) bool {
+	for _, s := range suppressed {
+		if s.RuleID == finding.RuleID && s.Path == finding.Path && s.Fingerprint == finding.Fingerprint &&
+			sameLines(s.Lines, finding.Lines) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameLines(a, b *config.LineRange) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"org.gkh/findcert/config"
+)
+
+// Reads every certificate from a PEM bundle or a single DER encoded file
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	return ParseCertificates(data)
+}
+
+// Parses all CERTIFICATE blocks in PEM data, falling back to DER
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, errors.New("no certificates found")
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// The line ranges of the PEM blocks in data whose type matches, in file order
+func PEMBlockLines(data []byte, match func(blockType string) bool) []config.LineRange {
+	var ranges []config.LineRange
+
+	offset := 0
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		end := len(data) - len(rest)
+		if match(block.Type) {
+			// Text skipped by pem.Decode may hold broken BEGIN lines, so the
+			// block starts at the last one before its END line
+			start := offset + bytes.LastIndex(data[offset:end], []byte("-----BEGIN "))
+			ranges = append(ranges, config.LineRange{
+				Start: bytes.Count(data[:start], []byte("\n")) + 1,
+				End:   bytes.Count(bytes.TrimRight(data[:end], "\r\n"), []byte("\n")) + 1,
+			})
+		}
+		offset = end
+	}
+	return ranges
+}
+
+// The SHA-256 fingerprint of the DER encoded certificate
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Summarizes a parsed certificate and its compliance result for the report
+func NewCertificateInfo(cert *x509.Certificate, result *FIPSResult) config.CertificateInfo {
+	size, curve := publicKeyParams(cert.PublicKey)
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return config.CertificateInfo{
+		Subject:              cert.Subject.String(),
+		Issuer:               cert.Issuer.String(),
+		SerialNumber:         cert.SerialNumber.Text(16),
+		NotBefore:            cert.NotBefore,
+		NotAfter:             cert.NotAfter,
+		SignatureAlgorithm:   cert.SignatureAlgorithm.String(),
+		PublicKeyAlgorithm:   cert.PublicKeyAlgorithm.String(),
+		PublicKeySize:        size,
+		PublicKeyCurve:       curve,
+		PublicKeyFingerprint: hex.EncodeToString(spki[:]),
+		IsCA:                 cert.IsCA,
+		DNSNames:             cert.DNSNames,
+		IPAddresses:          ipStrings(cert.IPAddresses),
+		Fingerprint:          Fingerprint(cert),
+		Compliant:            result.IsCompliant,
+		Revoked:              result.Revoked,
+		Violations:           result.Violations,
+		Lints:                result.Lints,
+	}
+}
+
+// The size in bits and, for elliptic curve keys, the curve of a public key
+func publicKeyParams(pubKey interface{}) (int, string) {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen(), ""
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize, key.Curve.Params().Name
+	case *dsa.PublicKey:
+		return key.P.BitLen(), ""
+	case ed25519.PublicKey:
+		return 256, "Ed25519"
+	default:
+		return 0, ""
+	}
+}
+
+func ipStrings(ips []net.IP) []string {
+	var out []string
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out
+}
@@ -8,13 +8,61 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"time"
+
+	"org.gkh/findcert/config"
 )
 
 type FIPSResult struct {
 	IsCompliant bool
+	Revoked     bool
 	Reasons     []string
+	// The reasons again, with the ID of the rule that failed
+	Violations []config.Finding
+	Lints      []config.Finding
+}
+
+// Thresholds, rules and owners applied when checking certificates and keys
+type Checker struct {
+	Thresholds    config.Thresholds
+	DisabledRules []string
+	AllowedOwners []string
+}
+
+// A checker with the default thresholds and every rule enabled
+func DefaultChecker() *Checker {
+	return &Checker{Thresholds: config.DefaultSettings().Thresholds}
+}
+
+// A checker for the thresholds and policy in the settings
+func NewChecker(settings config.Settings) *Checker {
+	return &Checker{
+		Thresholds:    settings.Thresholds,
+		DisabledRules: settings.Policy.DisabledRules,
+		AllowedOwners: settings.Policy.AllowedOwners,
+	}
+}
+
+func (c *Checker) enabled(ruleID string) bool {
+	for _, disabled := range c.DisabledRules {
+		if disabled == ruleID {
+			return false
+		}
+	}
+	return true
+}
+
+// Records a failed compliance rule
+func (r *FIPSResult) fail(ruleID, reason string) {
+	r.IsCompliant = false
+	r.Reasons = append(r.Reasons, reason)
+	r.Violations = append(r.Violations, config.Finding{
+		RuleID:   ruleID,
+		Severity: config.SeverityError,
+		Message:  reason,
+	})
 }
 
 // Is the provided X.509 certificate FIPS 140-3 compliant?
@@ -35,31 +83,39 @@ func IsFIPSCompliant(certPath string) (*FIPSResult, error) {
 		return nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
+	return CheckCertificate(cert), nil
+}
+
+// Is the parsed X.509 certificate FIPS 140-3 compliant?
+func CheckCertificate(cert *x509.Certificate) *FIPSResult {
+	return DefaultChecker().Check(cert)
+}
+
+// Is the parsed X.509 certificate compliant with the checker's thresholds?
+func (c *Checker) Check(cert *x509.Certificate) *FIPSResult {
 	result := &FIPSResult{
 		IsCompliant: true,
 		Reasons:     []string{},
+		Lints:       c.Lint(cert),
 	}
 
 	// Check signature algorithm
-	if !isFIPSSignatureAlgorithm(cert.SignatureAlgorithm) {
-		result.IsCompliant = false
-		result.Reasons = append(result.Reasons,
+	if c.enabled("fips-signature-algorithm") && !isFIPSSignatureAlgorithm(cert.SignatureAlgorithm) {
+		result.fail("fips-signature-algorithm",
 			fmt.Sprintf("Signature algorithm %v is not FIPS 140-3 compliant", cert.SignatureAlgorithm))
 	}
 
 	// Check public key algorithm and key size
-	if !isFIPSCompliantPublicKey(cert.PublicKey) {
-		result.IsCompliant = false
-		result.Reasons = append(result.Reasons, "Public key type or size is not FIPS 140-3 compliant")
+	if c.enabled("fips-public-key") && !isFIPSCompliantPublicKey(cert.PublicKey, c.Thresholds.MinRSABits) {
+		result.fail("fips-public-key", "Public key type or size is not FIPS 140-3 compliant")
 	}
 
 	// Check certificate expiration
-	if !hasExpired(cert) {
-		result.IsCompliant = false
-		result.Reasons = append(result.Reasons, "Certificate is expired or not yet valid")
+	if c.enabled("validity-period") && !hasExpired(cert) {
+		result.fail("validity-period", "Certificate is expired or not yet valid")
 	}
 
-	return result, nil
+	return result
 }
 
 // Is the signature algorithm is FIPS 140-3 compliant?
@@ -78,11 +134,16 @@ func isFIPSSignatureAlgorithm(sigAlg x509.SignatureAlgorithm) bool {
 }
 
 // I the public key type and size FIPS 140-3 compliant?
-func isFIPSCompliantPublicKey(pubKey interface{}) bool {
+func isFIPSCompliantPublicKey(pubKey interface{}, minRSABits int) bool {
+	// FIPS 140-3 never allows RSA keys below 2048 bits
+	if minRSABits < 2048 {
+		minRSABits = 2048
+	}
+
 	switch key := pubKey.(type) {
 	case *rsa.PublicKey:
 		// RSA keys must be at least 2048 bits
-		return key.N.BitLen() >= 2048
+		return key.N.BitLen() >= minRSABits
 	case *ecdsa.PublicKey:
 		// Check for approved curves (P-256, P-384, P-521)
 		curve := key.Curve.Params().Name
@@ -121,17 +182,24 @@ func GetCertificateExpirationInfo(cert *x509.Certificate) string {
 }
 
 // Prints the FIPS compliance check result
-func PrintFIPSResult(result *FIPSResult, cert *x509.Certificate) {
+func PrintFIPSResult(w io.Writer, result *FIPSResult, cert *x509.Certificate) {
 	if result.IsCompliant {
-		fmt.Println("Certificate is FIPS 140-3 compliant.")
+		fmt.Fprintln(w, "Certificate is FIPS 140-3 compliant.")
 	} else {
-		fmt.Println("Certificate is NOT FIPS 140-3 compliant for the following reasons:")
+		fmt.Fprintln(w, "Certificate is NOT FIPS 140-3 compliant for the following reasons:")
 		for _, reason := range result.Reasons {
-			fmt.Printf("- %s\n", reason)
+			fmt.Fprintf(w, "- %s\n", reason)
+		}
+	}
+
+	if len(result.Lints) > 0 {
+		fmt.Fprintln(w, "\nLint findings:")
+		for _, lint := range result.Lints {
+			fmt.Fprintf(w, "- [%s] %s: %s\n", lint.Severity, lint.RuleID, lint.Message)
 		}
 	}
 
 	// Print expiration information
-	fmt.Println("\nExpiration Information:")
-	fmt.Println(GetCertificateExpirationInfo(cert))
+	fmt.Fprintln(w, "\nExpiration Information:")
+	fmt.Fprintln(w, GetCertificateExpirationInfo(cert))
 }
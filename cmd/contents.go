@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"org.gkh/findcert/config"
+)
+
+// What inspecting a file needs from its contents: the certificates and the
+// private key found in it. Reading a file is most of the work of a scan, so
+// these are kept apart from the checks to be cached between scans.
+type FileContents struct {
+	// DER encoded certificates, looked for in every file but CRLs and key stores
+	Certificates [][]byte `json:"certificates,omitempty"`
+	// Where the PEM block of each certificate sits, in the same order; empty for DER files
+	CertificateLines []config.LineRange `json:"certificate_lines,omitempty"`
+	PrivateKey       bool               `json:"private_key,omitempty"`
+	PrivateKeyLines  []config.LineRange `json:"private_key_lines,omitempty"`
+	// See config.FileInfo.PrivateKeyFingerprints
+	PrivateKeyFingerprints []string `json:"private_key_fingerprints,omitempty"`
+}
+
+// Reads the file found with the given extension and parses its contents
+func ReadContents(extension, path string) (*FileContents, error) {
+	if keystoreExtensions[strings.ToLower(filepath.Ext(path))] {
+		return &FileContents{PrivateKey: true}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return ParseContents(extension, path, data), nil
+}
+
+// Finds the certificates and private key in data read from path
+func ParseContents(extension, path string, data []byte) *FileContents {
+	contents := &FileContents{}
+	if keystoreExtensions[strings.ToLower(filepath.Ext(path))] {
+		contents.PrivateKey = true
+		return contents
+	}
+
+	// The contents decide what a file holds, so that any configured
+	// extension can carry PEM or DER certificates
+	if extension != ".crl" {
+		// Keys, requests and other PEM files are not certificates
+		if certs, err := ParseCertificates(data); err == nil {
+			for _, cert := range certs {
+				contents.Certificates = append(contents.Certificates, cert.Raw)
+			}
+			contents.CertificateLines = PEMBlockLines(data, func(blockType string) bool { return blockType == "CERTIFICATE" })
+		}
+	}
+	if len(data) <= maxKeyFileSize {
+		privateKeyIn(contents, data)
+	}
+	return contents
+}
+
+// Looks for private keys in data and records them, the lines of their PEM
+// blocks and the fingerprints of their public halves in contents
+func privateKeyIn(contents *FileContents, data []byte) {
+	if block, _ := pem.Decode(data); block != nil {
+		contents.PrivateKeyLines = PEMBlockLines(data, isPrivateKeyBlock)
+		contents.PrivateKey = len(contents.PrivateKeyLines) > 0
+		for rest := data; ; {
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if !isPrivateKeyBlock(block.Type) {
+				continue
+			}
+			// Encrypted keys can not be parsed and are left without a fingerprint
+			if key, err := parsePrivateKey(block.Bytes); err == nil {
+				contents.addFingerprint(key)
+			}
+		}
+		return
+	}
+
+	// Raw DER private keys
+	if key, err := parsePrivateKey(data); err == nil {
+		contents.PrivateKey = true
+		contents.addFingerprint(key)
+	}
+}
+
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	return x509.ParseECPrivateKey(der)
+}
+
+// Records the SHA-256 fingerprint of the key's public half, computed like
+// that of a certificate's public key
+func (c *FileContents) addFingerprint(key crypto.PrivateKey) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return
+	}
+	spki, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(spki)
+	c.PrivateKeyFingerprints = append(c.PrivateKeyFingerprints, hex.EncodeToString(sum[:]))
+}
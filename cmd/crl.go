@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Reads a certificate revocation list in PEM or DER form
+func LoadCRL(path string) (*x509.RevocationList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL file: %w", err)
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CRL: %w", err)
+	}
+	return crl, nil
+}
+
+// Returns the certificate whose key signed the CRL, or nil if none did
+func VerifyCRL(crl *x509.RevocationList, issuers []*x509.Certificate) *x509.Certificate {
+	for _, issuer := range issuers {
+		if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(issuer); err == nil {
+			return issuer
+		}
+	}
+	return nil
+}
+
+// Is the CRL past its nextUpdate time?
+func IsStaleCRL(crl *x509.RevocationList) bool {
+	return !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate)
+}
+
+// Marks the result as revoked if the certificate's serial appears in a CRL from its issuer
+func CheckRevocation(result *FIPSResult, cert *x509.Certificate, crls []*x509.RevocationList) {
+	for _, crl := range crls {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+				continue
+			}
+			result.Revoked = true
+			result.fail("revoked", fmt.Sprintf("Certificate was revoked on %s", entry.RevocationTime.Format("Jan 2, 2006")))
+			return
+		}
+	}
+}
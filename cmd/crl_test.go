@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Creates a certificate from the template, signed by parent or self-signed when parent is nil
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil)
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestInspectResults_MarksRevokedCertificates(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ca := newTestCA(t, "Test CA")
+	revoked := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1001),
+		Subject:      pkix.Name{CommonName: "revoked.example.com"},
+	}, ca)
+	good := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1002),
+		Subject:      pkix.Name{CommonName: "good.example.com"},
+	}, ca)
+
+	writePEM(t, filepath.Join(tempDir, "ca.pem"), "CERTIFICATE", ca.cert.Raw)
+	writePEM(t, filepath.Join(tempDir, "revoked.crt"), "CERTIFICATE", revoked.cert.Raw)
+	writePEM(t, filepath.Join(tempDir, "good.crt"), "CERTIFICATE", good.cert.Raw)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(7),
+		ThisUpdate: time.Now().Add(-48 * time.Hour),
+		NextUpdate: time.Now().Add(-24 * time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: revoked.cert.SerialNumber, RevocationTime: time.Now().Add(-72 * time.Hour)},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	// DER encoded, as most CRL distribution points publish them
+	if err := os.WriteFile(filepath.Join(tempDir, "ca.crl"), crl, 0644); err != nil {
+		t.Fatalf("Failed to write CRL: %v", err)
+	}
+
+	results, err := ListCertificates(tempDir, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	crls := InspectResults(results)
+
+	if len(crls) != 1 {
+		t.Fatalf("Expected 1 CRL, got %d", len(crls))
+	}
+	if !crls[0].Verified {
+		t.Errorf("Expected CRL signature to be verified, error: %s", crls[0].Error)
+	}
+	if !crls[0].Stale {
+		t.Error("Expected CRL past its next update to be stale")
+	}
+	if crls[0].RevokedCount != 1 {
+		t.Errorf("Expected 1 revoked entry, got %d", crls[0].RevokedCount)
+	}
+
+	status := make(map[string]bool)
+	for _, result := range results {
+		for _, file := range result.Files {
+			for _, cert := range file.Certificates {
+				status[filepath.Base(file.Path)] = cert.Revoked
+			}
+		}
+	}
+
+	if !status["revoked.crt"] {
+		t.Error("Expected revoked.crt to be marked as revoked")
+	}
+	if status["good.crt"] {
+		t.Error("Expected good.crt not to be marked as revoked")
+	}
+}
+
+func TestInspectResults_IgnoresUnverifiedCRL(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ca := newTestCA(t, "Test CA")
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+	}, ca)
+	writePEM(t, filepath.Join(tempDir, "leaf.pem"), "CERTIFICATE", leaf.cert.Raw)
+
+	// The CA certificate is not on disk, so the CRL cannot be trusted
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(24 * time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: leaf.cert.SerialNumber, RevocationTime: time.Now()},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	writePEM(t, filepath.Join(tempDir, "ca.crl"), "X509 CRL", crl)
+
+	results, err := ListCertificates(tempDir, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	crls := InspectResults(results)
+
+	if len(crls) != 1 || crls[0].Verified {
+		t.Fatalf("Expected 1 unverified CRL, got %+v", crls)
+	}
+	for _, result := range results {
+		for _, file := range result.Files {
+			for _, cert := range file.Certificates {
+				if cert.Revoked {
+					t.Errorf("Certificate %s marked revoked by an unverified CRL", file.Path)
+				}
+			}
+		}
+	}
+}
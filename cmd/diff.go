@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Reads a results file written by scan -format json
+func LoadSearchResult(path string) (*config.SearchResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	var result config.SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse results %s: %w", path, err)
+	}
+
+	// Files from before schema_version existed are read as version 1
+	major, _, _ := strings.Cut(result.SchemaVersion, ".")
+	current, _, _ := strings.Cut(config.SchemaVersion, ".")
+	if result.SchemaVersion != "" && major != current {
+		return nil, fmt.Errorf("%s has schema version %s, expected %s.x", path, result.SchemaVersion, current)
+	}
+	return &result, nil
+}
+
+// A certificate of one scan with every file it was found in
+type scannedCertificate struct {
+	info  config.CertificateInfo
+	paths []string
+}
+
+// Compares two scans. Certificates are matched by fingerprint; a certificate
+// that disappeared and one that appeared with the same subject and SANs are
+// reported as a renewal rather than a removal and an addition.
+func DiffResults(previous, current *config.SearchResult) *config.ResultDiff {
+	diff := &config.ResultDiff{
+		Old:               config.DiffSource{SearchPath: previous.SearchPath, SearchTime: previous.SearchTime},
+		New:               config.DiffSource{SearchPath: current.SearchPath, SearchTime: current.SearchTime},
+		Added:             []config.DiffCertificate{},
+		Removed:           []config.DiffCertificate{},
+		Renewed:           []config.Renewal{},
+		Moved:             []config.Move{},
+		ComplianceChanged: []config.ComplianceChange{},
+	}
+
+	oldCerts, oldOrder := indexCertificates(previous)
+	newCerts, newOrder := indexCertificates(current)
+
+	var removed, added []*scannedCertificate
+	for _, fingerprint := range oldOrder {
+		before := oldCerts[fingerprint]
+		after, ok := newCerts[fingerprint]
+		if !ok {
+			removed = append(removed, before)
+			continue
+		}
+
+		if !samePaths(before.paths, after.paths) {
+			diff.Moved = append(diff.Moved, config.Move{
+				Subject:     after.info.Subject,
+				Fingerprint: fingerprint,
+				OldPaths:    before.paths,
+				NewPaths:    after.paths,
+			})
+		}
+		if before.info.ComplianceStatus() != after.info.ComplianceStatus() {
+			diff.ComplianceChanged = append(diff.ComplianceChanged, config.ComplianceChange{
+				DiffCertificate: after.diffCertificate(),
+				OldCompliance:   before.info.ComplianceStatus(),
+				Violations:      after.info.Violations,
+			})
+		}
+	}
+	for _, fingerprint := range newOrder {
+		if _, ok := oldCerts[fingerprint]; !ok {
+			added = append(added, newCerts[fingerprint])
+		}
+	}
+
+	// Pair renewals, oldest expiry with oldest expiry
+	sort.SliceStable(removed, func(i, j int) bool { return removed[i].info.NotAfter.Before(removed[j].info.NotAfter) })
+	sort.SliceStable(added, func(i, j int) bool { return added[i].info.NotAfter.Before(added[j].info.NotAfter) })
+	renewedBy := make(map[*scannedCertificate]bool)
+	for _, before := range removed {
+		var renewal *scannedCertificate
+		for _, after := range added {
+			if !renewedBy[after] && isRenewal(&before.info, &after.info) {
+				renewal = after
+				break
+			}
+		}
+		if renewal == nil {
+			diff.Removed = append(diff.Removed, before.diffCertificate())
+			continue
+		}
+		renewedBy[renewal] = true
+		diff.Renewed = append(diff.Renewed, config.Renewal{Old: before.diffCertificate(), New: renewal.diffCertificate()})
+	}
+	for _, after := range added {
+		if !renewedBy[after] {
+			diff.Added = append(diff.Added, after.diffCertificate())
+		}
+	}
+
+	return diff
+}
+
+// Groups the certificates of a scan by fingerprint, in the order they were found
+func indexCertificates(result *config.SearchResult) (map[string]*scannedCertificate, []string) {
+	certs := make(map[string]*scannedCertificate)
+	var order []string
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for _, cert := range file.Certificates {
+				scanned, ok := certs[cert.Fingerprint]
+				if !ok {
+					scanned = &scannedCertificate{info: cert}
+					certs[cert.Fingerprint] = scanned
+					order = append(order, cert.Fingerprint)
+				}
+				scanned.paths = append(scanned.paths, file.Path)
+			}
+		}
+	}
+	for _, scanned := range certs {
+		sort.Strings(scanned.paths)
+	}
+	return certs, order
+}
+
+func (s *scannedCertificate) diffCertificate() config.DiffCertificate {
+	return config.DiffCertificate{
+		Subject:      s.info.Subject,
+		SerialNumber: s.info.SerialNumber,
+		NotAfter:     s.info.NotAfter,
+		Fingerprint:  s.info.Fingerprint,
+		Compliance:   s.info.ComplianceStatus(),
+		Paths:        s.paths,
+	}
+}
+
+// Same subject and SANs, but a new serial number
+func isRenewal(before, after *config.CertificateInfo) bool {
+	return before.Subject == after.Subject &&
+		before.SerialNumber != after.SerialNumber &&
+		sameNames(before.DNSNames, after.DNSNames) &&
+		sameNames(before.IPAddresses, after.IPAddresses)
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return samePaths(a, b)
+}
+
+func samePaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Prints the changes between two scans
+func PrintDiff(w io.Writer, diff *config.ResultDiff) {
+	fmt.Fprintf(w, "Comparing %s (%s) with %s (%s)\n",
+		diff.Old.File, diff.Old.SearchTime.Format(time.RFC3339), diff.New.File, diff.New.SearchTime.Format(time.RFC3339))
+	if diff.Empty() {
+		fmt.Fprintln(w, "No certificate changes")
+		return
+	}
+
+	if len(diff.Added) > 0 {
+		fmt.Fprintf(w, "\nAdded (%d):\n", len(diff.Added))
+		for _, cert := range diff.Added {
+			fmt.Fprintf(w, "+ %s (serial %s, expires %s, %s)\n", cert.Subject, cert.SerialNumber, cert.NotAfter.Format("Jan 2, 2006"), cert.Compliance)
+			printPaths(w, cert.Paths)
+		}
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Fprintf(w, "\nRemoved (%d):\n", len(diff.Removed))
+		for _, cert := range diff.Removed {
+			fmt.Fprintf(w, "- %s (serial %s, expires %s)\n", cert.Subject, cert.SerialNumber, cert.NotAfter.Format("Jan 2, 2006"))
+			printPaths(w, cert.Paths)
+		}
+	}
+	if len(diff.Renewed) > 0 {
+		fmt.Fprintf(w, "\nRenewed (%d):\n", len(diff.Renewed))
+		for _, renewal := range diff.Renewed {
+			fmt.Fprintf(w, "~ %s: serial %s -> %s, expires %s -> %s\n", renewal.New.Subject,
+				renewal.Old.SerialNumber, renewal.New.SerialNumber,
+				renewal.Old.NotAfter.Format("Jan 2, 2006"), renewal.New.NotAfter.Format("Jan 2, 2006"))
+			printPaths(w, renewal.New.Paths)
+		}
+	}
+	if len(diff.Moved) > 0 {
+		fmt.Fprintf(w, "\nMoved (%d):\n", len(diff.Moved))
+		for _, move := range diff.Moved {
+			fmt.Fprintf(w, "> %s\n", move.Subject)
+			fmt.Fprintf(w, "    from %s\n", strings.Join(move.OldPaths, ", "))
+			fmt.Fprintf(w, "    to   %s\n", strings.Join(move.NewPaths, ", "))
+		}
+	}
+	if len(diff.ComplianceChanged) > 0 {
+		fmt.Fprintf(w, "\nCompliance changed (%d):\n", len(diff.ComplianceChanged))
+		for _, change := range diff.ComplianceChanged {
+			fmt.Fprintf(w, "! %s: %s -> %s\n", change.Subject, change.OldCompliance, change.Compliance)
+			for _, violation := range change.Violations {
+				fmt.Fprintf(w, "    [%s] %s\n", violation.RuleID, violation.Message)
+			}
+		}
+	}
+}
+
+func printPaths(w io.Writer, paths []string) {
+	for _, path := range paths {
+		fmt.Fprintf(w, "    %s\n", path)
+	}
+}
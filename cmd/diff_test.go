@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func scanOf(files map[string][]config.CertificateInfo) *config.SearchResult {
+	result := &config.SearchResult{Results: []config.ExtensionResult{{Type: ".pem"}}}
+	for path, certs := range files {
+		result.Results[0].Files = append(result.Results[0].Files, config.FileInfo{Path: path, Certificates: certs})
+	}
+	return result
+}
+
+func TestDiffResults(t *testing.T) {
+	expiry := time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+	web := config.CertificateInfo{Subject: "CN=www", SerialNumber: "1", DNSNames: []string{"www.example.com"}, Fingerprint: "aa", NotAfter: expiry, Compliant: true}
+	webRenewed := config.CertificateInfo{Subject: "CN=www", SerialNumber: "2", DNSNames: []string{"www.example.com"}, Fingerprint: "bb", NotAfter: expiry.AddDate(1, 0, 0), Compliant: true}
+	mail := config.CertificateInfo{Subject: "CN=mail", SerialNumber: "3", Fingerprint: "cc", NotAfter: expiry, Compliant: true}
+	mailRevoked := mail
+	mailRevoked.Revoked = true
+	mailRevoked.Compliant = false
+	mailRevoked.Violations = []config.Finding{{RuleID: "revoked", Severity: config.SeverityError, Message: "Certificate was revoked"}}
+	legacy := config.CertificateInfo{Subject: "CN=legacy", SerialNumber: "4", Fingerprint: "dd", NotAfter: expiry}
+	api := config.CertificateInfo{Subject: "CN=api", SerialNumber: "5", Fingerprint: "ee", NotAfter: expiry}
+	// Same subject as web but other SANs is not a renewal
+	webOther := config.CertificateInfo{Subject: "CN=www", SerialNumber: "6", DNSNames: []string{"www2.example.com"}, Fingerprint: "ff", NotAfter: expiry}
+
+	previous := scanOf(map[string][]config.CertificateInfo{
+		"/etc/ssl/www.pem":    {web},
+		"/etc/ssl/mail.pem":   {mail},
+		"/etc/ssl/legacy.pem": {legacy},
+		"/etc/ssl/api.pem":    {api},
+	})
+	current := scanOf(map[string][]config.CertificateInfo{
+		"/etc/ssl/www.pem":     {webRenewed},
+		"/etc/ssl/www2.pem":    {webOther},
+		"/etc/ssl/mail.pem":    {mailRevoked},
+		"/etc/pki/tls/api.pem": {api},
+	})
+
+	diff := DiffResults(previous, current)
+
+	if len(diff.Renewed) != 1 || diff.Renewed[0].Old.SerialNumber != "1" || diff.Renewed[0].New.SerialNumber != "2" {
+		t.Errorf("Expected www to be renewed, got %+v", diff.Renewed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Fingerprint != "ff" {
+		t.Errorf("Expected www2 to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Fingerprint != "dd" {
+		t.Errorf("Expected legacy to be removed, got %+v", diff.Removed)
+	}
+	if len(diff.Moved) != 1 || diff.Moved[0].NewPaths[0] != "/etc/pki/tls/api.pem" {
+		t.Errorf("Expected api to be moved, got %+v", diff.Moved)
+	}
+	if len(diff.ComplianceChanged) != 1 || diff.ComplianceChanged[0].OldCompliance != "compliant" || diff.ComplianceChanged[0].Compliance != "revoked" {
+		t.Errorf("Expected mail to become revoked, got %+v", diff.ComplianceChanged)
+	}
+
+	if !DiffResults(current, current).Empty() {
+		t.Error("Expected no changes between identical scans")
+	}
+}
+
+func TestLoadSearchResult_RejectsOtherMajorVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(`{"schema_version": "2.0", "results": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSearchResult(path); err == nil {
+		t.Error("Expected results of another major version to be rejected")
+	}
+
+	// Results written before the version existed are still readable
+	if err := os.WriteFile(path, []byte(`{"search_path": "/etc/ssl", "results": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSearchResult(path); err != nil {
+		t.Errorf("Expected unversioned results to load, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// A rule that can produce findings
+type Rule struct {
+	ID          string
+	Severity    string
+	Description string
+}
+
+// The rules that are not certificate lints, in reporting order
+var checkRules = []Rule{
+	{"fips-signature-algorithm", config.SeverityError, "Certificates must be signed with a FIPS 140-3 approved signature algorithm"},
+	{"fips-public-key", config.SeverityError, "Certificate keys must be FIPS 140-3 approved algorithms and sizes"},
+	{"validity-period", config.SeverityError, "Certificates must be within their validity period"},
+	{"revoked", config.SeverityError, "Certificates must not be revoked by their issuer's CRL or OCSP responder"},
+	{"private-key-file", config.SeverityWarning, "Private keys and key stores should not be left in scanned locations such as source trees"},
+	{"key-file-world-readable", config.SeverityError, "Private keys must not be readable by all users"},
+	{"key-file-group-readable", config.SeverityWarning, "Private keys should not be readable by their group"},
+	{"key-file-owner", config.SeverityError, "Private keys must be owned by an allowed owner"},
+	{"key-dir-world-writable", config.SeverityError, "Private keys must not be kept in world-writable directories"},
+	{"crl-invalid", config.SeverityError, "CRL files must be parsable"},
+	{"crl-unverified", config.SeverityWarning, "CRLs should be signed by a certificate found in the scan"},
+	{"crl-stale", config.SeverityWarning, "CRLs should not be past their next update time"},
+	{"host-not-covered", config.SeverityError, "Every expected host name must be covered by a valid certificate"},
+}
+
+// Every rule findcert applies, compliance and file rules first, then lints
+func Rules() []Rule {
+	rules := append([]Rule(nil), checkRules...)
+	for _, lint := range LintRules {
+		rules = append(rules, Rule{ID: lint.ID, Severity: lint.Severity, Description: lint.Description})
+	}
+	return rules
+}
+
+// Flattens the compliance violations, lints, private keys, permission issues,
+// CRL problems and uncovered hosts of a search into one list
+func CollectFindings(result *config.SearchResult) []config.LocatedFinding {
+	var findings []config.LocatedFinding
+	for _, extResult := range result.Results {
+		for i := range extResult.Files {
+			findings = append(findings, ReportedFileFindings(result, &extResult.Files[i])...)
+		}
+	}
+	return append(findings, ReportedResultFindings(result)...)
+}
+
+// The findings of one file of the result, without those of the rules the
+// result's policy turned off
+func ReportedFileFindings(result *config.SearchResult, file *config.FileInfo) []config.LocatedFinding {
+	return withoutDisabled(result, FileFindings(file))
+}
+
+// The CRL and host findings of the result, without those of the rules the
+// result's policy turned off and those a baseline accepted
+func ReportedResultFindings(result *config.SearchResult) []config.LocatedFinding {
+	var findings []config.LocatedFinding
+	for _, finding := range append(CRLFindings(result.CRLs), HostFindings(result.Hosts)...) {
+		if !isSuppressed(result.Suppressed, finding) {
+			findings = append(findings, finding)
+		}
+	}
+	return withoutDisabled(result, findings)
+}
+
+func withoutDisabled(result *config.SearchResult, findings []config.LocatedFinding) []config.LocatedFinding {
+	if len(result.DisabledRules) == 0 {
+		return findings
+	}
+	return (&Checker{DisabledRules: result.DisabledRules}).Filter(findings)
+}
+
+// The private key, permission, compliance and lint findings of one file
+func FileFindings(file *config.FileInfo) []config.LocatedFinding {
+	var findings []config.LocatedFinding
+
+	if file.PrivateKey {
+		findings = append(findings, privateKeyFindings(file)...)
+	}
+	for _, issue := range file.PermissionIssues {
+		findings = append(findings, config.LocatedFinding{Finding: issue, Path: file.Path})
+	}
+	for _, cert := range file.Certificates {
+		for _, list := range [][]config.Finding{cert.Violations, cert.Lints} {
+			for _, finding := range list {
+				findings = append(findings, config.LocatedFinding{
+					Finding:     finding,
+					Path:        file.Path,
+					Subject:     cert.Subject,
+					Fingerprint: cert.Fingerprint,
+					Lines:       cert.Lines,
+				})
+			}
+		}
+	}
+
+	if len(file.Suppressed) == 0 {
+		return findings
+	}
+	var kept []config.LocatedFinding
+	for _, finding := range findings {
+		if !isSuppressed(file.Suppressed, finding) {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
+
+func privateKeyFindings(file *config.FileInfo) []config.LocatedFinding {
+	located := config.LocatedFinding{Finding: config.Finding{
+		RuleID:   "private-key-file",
+		Severity: config.SeverityWarning,
+		Message:  "File contains a private key or key store",
+	}, Path: file.Path}
+	if len(file.PrivateKeyLines) == 0 {
+		return []config.LocatedFinding{located}
+	}
+
+	var findings []config.LocatedFinding
+	for k := range file.PrivateKeyLines {
+		located.Lines = &file.PrivateKeyLines[k]
+		findings = append(findings, located)
+	}
+	return findings
+}
+
+// Findings for CRLs that could not be parsed, verified or are stale
+func CRLFindings(crls []config.CRLInfo) []config.LocatedFinding {
+	var findings []config.LocatedFinding
+	for _, crl := range crls {
+		var finding config.Finding
+		switch {
+		case crl.Error != "":
+			finding = config.Finding{RuleID: "crl-invalid", Severity: config.SeverityError, Message: crl.Error}
+		case !crl.Verified:
+			finding = config.Finding{RuleID: "crl-unverified", Severity: config.SeverityWarning,
+				Message: "CRL signature could not be verified against any certificate found"}
+		case crl.Stale:
+			finding = config.Finding{RuleID: "crl-stale", Severity: config.SeverityWarning,
+				Message: fmt.Sprintf("CRL next update was due %s", crl.NextUpdate.Format(time.RFC3339))}
+		default:
+			continue
+		}
+		findings = append(findings, config.LocatedFinding{Finding: finding, Path: crl.Path, Subject: crl.Issuer})
+	}
+	return findings
+}
+
+// Findings for expected hosts no certificate covers
+func HostFindings(hosts []config.HostCoverage) []config.LocatedFinding {
+	var findings []config.LocatedFinding
+	for _, coverage := range hosts {
+		if len(coverage.Paths) == 0 {
+			findings = append(findings, config.LocatedFinding{Finding: config.Finding{
+				RuleID:   "host-not-covered",
+				Severity: config.SeverityError,
+				Message:  fmt.Sprintf("no valid certificate covers %s", coverage.Host),
+			}})
+		}
+	}
+	return findings
+}
+
+// Drops findings of disabled rules
+func (c *Checker) Filter(findings []config.LocatedFinding) []config.LocatedFinding {
+	var kept []config.LocatedFinding
+	for _, finding := range findings {
+		if c.enabled(finding.RuleID) {
+			kept = append(kept, finding)
+		}
+	}
+	return kept
+}
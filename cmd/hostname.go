@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Whether one certificate is valid for a host name
+type HostnameResult struct {
+	Subject string `json:"subject"`
+	Valid   bool   `json:"valid"`
+	Error   string `json:"error,omitempty"`
+}
+
+// The end-entity certificates of a bundle; a bundle of only CAs is returned as is
+func Leaves(certs []*x509.Certificate) []*x509.Certificate {
+	var leaves []*x509.Certificate
+	for _, cert := range certs {
+		if !cert.IsCA {
+			leaves = append(leaves, cert)
+		}
+	}
+	if len(leaves) == 0 {
+		return certs
+	}
+	return leaves
+}
+
+// Checks each leaf of the bundle against a DNS name or IP address using
+// subjectAltName semantics, including wildcards and IP SANs
+func CheckHostname(certs []*x509.Certificate, host string) []HostnameResult {
+	var results []HostnameResult
+	for _, cert := range Leaves(certs) {
+		result := HostnameResult{Subject: cert.Subject.String(), Valid: true}
+		if err := cert.VerifyHostname(host); err != nil {
+			result.Valid = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Finds which scanned certificates cover each expected host. Only leaves that
+// are currently valid and not revoked count as covering a host.
+func FindHostCoverage(results []config.ExtensionResult, hosts []string) []config.HostCoverage {
+	tracker := NewHostTracker(hosts)
+	for _, result := range results {
+		for i := range result.Files {
+			tracker.Add(&result.Files[i])
+		}
+	}
+	return tracker.Coverage(nil)
+}
+
+// Works out host coverage one file at a time, for scans that do not keep
+// their results
+type HostTracker struct {
+	hosts    []string
+	covering [][]coveringFile
+	now      time.Time
+}
+
+type coveringFile struct {
+	path         string
+	fingerprints []string
+}
+
+func NewHostTracker(hosts []string) *HostTracker {
+	return &HostTracker{hosts: hosts, covering: make([][]coveringFile, len(hosts)), now: time.Now()}
+}
+
+// Records the file for every host one of its certificates covers
+func (t *HostTracker) Add(file *config.FileInfo) {
+	for i, host := range t.hosts {
+		covering := coveringFile{path: file.Path}
+		for _, cert := range file.Certificates {
+			if cert.IsCA || cert.Revoked || t.now.Before(cert.NotBefore) || t.now.After(cert.NotAfter) {
+				continue
+			}
+			if coversHost(cert, host) {
+				covering.fingerprints = append(covering.fingerprints, cert.Fingerprint)
+			}
+		}
+		if len(covering.fingerprints) > 0 {
+			t.covering[i] = append(t.covering[i], covering)
+		}
+	}
+}
+
+// The files covering each host, leaving out certificates whose fingerprint is
+// in revoked. A file still covers a host through its other certificates.
+func (t *HostTracker) Coverage(revoked map[string]bool) []config.HostCoverage {
+	coverage := make([]config.HostCoverage, len(t.hosts))
+	for i, host := range t.hosts {
+		coverage[i] = config.HostCoverage{Host: host, Paths: []string{}}
+		for _, covering := range t.covering[i] {
+			for _, fingerprint := range covering.fingerprints {
+				if !revoked[fingerprint] {
+					coverage[i].Paths = append(coverage[i].Paths, covering.path)
+					break
+				}
+			}
+		}
+	}
+	return coverage
+}
+
+// Matches a host against the SANs recorded in the scan results
+func coversHost(info config.CertificateInfo, host string) bool {
+	cert := &x509.Certificate{DNSNames: info.DNSNames}
+	for _, ip := range info.IPAddresses {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			cert.IPAddresses = append(cert.IPAddresses, parsed)
+		}
+	}
+	return cert.VerifyHostname(host) == nil
+}
+
+// Prints the host name check result for each leaf
+func PrintHostnameResults(w io.Writer, host string, results []HostnameResult) {
+	fmt.Fprintf(w, "\nHost name %s:\n", host)
+	for _, result := range results {
+		if result.Valid {
+			fmt.Fprintf(w, "- %s: valid\n", result.Subject)
+		} else {
+			fmt.Fprintf(w, "- %s: NOT valid (%s)\n", result.Subject, result.Error)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckHostname_WildcardAndIP(t *testing.T) {
+	ca := newTestCA(t, "Host CA")
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(77),
+		Subject:      pkix.Name{CommonName: "api.example.com"},
+		DNSNames:     []string{"*.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.5")},
+	}, ca)
+	bundle := []*x509.Certificate{leaf.cert, ca.cert}
+
+	tests := []struct {
+		host  string
+		valid bool
+	}{
+		{"api.example.com", true},
+		{"10.0.0.5", true},
+		{"example.com", false},     // wildcards do not match the apex
+		{"a.b.example.com", false}, // or more than one label
+		{"10.0.0.6", false},
+	}
+
+	for _, tt := range tests {
+		results := CheckHostname(bundle, tt.host)
+		if len(results) != 1 {
+			t.Fatalf("Expected only the leaf to be checked, got %d results", len(results))
+		}
+		if results[0].Valid != tt.valid {
+			t.Errorf("Host %s: expected valid=%v, got %v (%s)", tt.host, tt.valid, results[0].Valid, results[0].Error)
+		}
+	}
+}
+
+func TestFindHostCoverage_ReportsUncoveredHosts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ca := newTestCA(t, "Host CA")
+	current := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(78),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+	}, ca)
+	expired := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(79),
+		Subject:      pkix.Name{CommonName: "old.example.com"},
+		DNSNames:     []string{"old.example.com"},
+		NotBefore:    time.Now().Add(-60 * 24 * time.Hour),
+		NotAfter:     time.Now().Add(-30 * 24 * time.Hour),
+	}, ca)
+	writePEM(t, filepath.Join(tempDir, "www.pem"), "CERTIFICATE", current.cert.Raw)
+	writePEM(t, filepath.Join(tempDir, "old.pem"), "CERTIFICATE", expired.cert.Raw)
+
+	results, err := ListCertificates(tempDir, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	InspectResults(results)
+
+	coverage := FindHostCoverage(results, []string{"www.example.com", "old.example.com", "missing.example.com"})
+
+	if len(coverage[0].Paths) != 1 {
+		t.Errorf("Expected www.example.com to be covered once, got %v", coverage[0].Paths)
+	}
+	if len(coverage[1].Paths) != 0 {
+		t.Errorf("Expected an expired certificate not to cover old.example.com, got %v", coverage[1].Paths)
+	}
+	if len(coverage[2].Paths) != 0 {
+		t.Errorf("Expected missing.example.com to be uncovered, got %v", coverage[2].Paths)
+	}
+}
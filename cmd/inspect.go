@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"crypto/x509"
+
+	"org.gkh/findcert/config"
+)
+
+// Parses the certificates and CRLs found by ListCertificates, records the
+// compliance of each certificate and checks it against the CRLs that were
+// signed by a certificate found in the same scan
+func InspectResults(results []config.ExtensionResult) []config.CRLInfo {
+	return DefaultChecker().InspectResults(results)
+}
+
+// InspectResults with the checker's thresholds and rules
+func (c *Checker) InspectResults(results []config.ExtensionResult) []config.CRLInfo {
+	inspector := c.NewInspector()
+	files := make(map[string]*config.FileInfo)
+	for i := range results {
+		for j := range results[i].Files {
+			file := &results[i].Files[j]
+			inspector.Inspect(results[i].Type, file)
+			files[file.Path] = file
+		}
+	}
+
+	crls, revocations := inspector.Finish()
+	for _, revocation := range revocations {
+		revocation.Apply(&files[revocation.Path].Certificates[revocation.Index])
+	}
+	return crls
+}
+
+// Inspects files one at a time, as a search finds them. A CRL can only be
+// trusted once the certificate that signed it has been seen, so CRLs are
+// verified and applied by Finish after the last file.
+type Inspector struct {
+	checker  *Checker
+	certs    []inspectedCertificate
+	crlPaths []string
+	// Found outside the inspected files, see AddKnown
+	issuers []*x509.Certificate
+	crls    []*x509.RevocationList
+}
+
+// A parsed certificate kept for the revocation check
+type inspectedCertificate struct {
+	path  string
+	index int
+	lines *config.LineRange
+	cert  *x509.Certificate
+}
+
+// A certificate found revoked by a verified CRL
+type Revocation struct {
+	// The file and the position of the certificate in its Certificates
+	Path        string
+	Index       int
+	Subject     string
+	Fingerprint string
+	Lines       *config.LineRange
+	Finding     config.Finding
+}
+
+func (c *Checker) NewInspector() *Inspector {
+	return &Inspector{checker: c}
+}
+
+// Parses and checks the certificates in a file found with the given
+// extension, whatever the extension is. CRL files are only recorded for Finish.
+func (in *Inspector) Inspect(extension string, file *config.FileInfo) {
+	if extension == ".crl" {
+		in.InspectContents(extension, file, nil)
+		return
+	}
+	contents, err := ReadContents(extension, file.Path)
+	if err != nil {
+		file.Error = err.Error()
+		return
+	}
+	in.InspectContents(extension, file, contents)
+}
+
+// Inspect with the contents already read, nil for CRL files
+func (in *Inspector) InspectContents(extension string, file *config.FileInfo, contents *FileContents) {
+	if extension == ".crl" {
+		in.crlPaths = append(in.crlPaths, file.Path)
+		return
+	}
+	if contents == nil {
+		return
+	}
+
+	for k, der := range contents.Certificates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		info := NewCertificateInfo(cert, in.checker.Check(cert))
+		if k < len(contents.CertificateLines) {
+			info.Lines = &contents.CertificateLines[k]
+		}
+		in.certs = append(in.certs, inspectedCertificate{path: file.Path, index: len(file.Certificates), lines: info.Lines, cert: cert})
+		file.Certificates = append(file.Certificates, info)
+	}
+}
+
+// Takes back a file inspected by an earlier run, such as one recorded in a
+// checkpoint, without reading or checking it again. der holds the file's
+// certificates, which are parsed again for the revocation check; those that
+// do not match the file's certificates are left out. A file recorded without
+// its certificates, as by scans that discard files, gets them back from der
+// without their compliance.
+func (in *Inspector) Restore(extension string, file *config.FileInfo, der [][]byte) {
+	if extension == ".crl" {
+		in.crlPaths = append(in.crlPaths, file.Path)
+		return
+	}
+
+	rebuild := len(file.Certificates) == 0
+	for _, data := range der {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			continue
+		}
+		if rebuild {
+			file.Certificates = append(file.Certificates, NewCertificateInfo(cert, &FIPSResult{}))
+		}
+		fingerprint := Fingerprint(cert)
+		for k := range file.Certificates {
+			if file.Certificates[k].Fingerprint == fingerprint {
+				in.certs = append(in.certs, inspectedCertificate{path: file.Path, index: k, lines: file.Certificates[k].Lines, cert: cert})
+				break
+			}
+		}
+	}
+}
+
+// Adds certificates and CRLs found outside the inspected files, such as in
+// the rest of a tree one file of which is inspected again. The CRLs of the
+// inspected files may be signed by the issuers, and the inspected
+// certificates are checked against the CRLs that a known or inspected
+// certificate signed. Neither is reported by Finish.
+func (in *Inspector) AddKnown(issuers []*x509.Certificate, crls []*x509.RevocationList) {
+	in.issuers = append(in.issuers, issuers...)
+	in.crls = append(in.crls, crls...)
+}
+
+// Verifies the CRLs against every certificate inspected and returns them,
+// together with the certificates they revoke
+func (in *Inspector) Finish() ([]config.CRLInfo, []Revocation) {
+	issuers := append([]*x509.Certificate(nil), in.issuers...)
+	for _, inspected := range in.certs {
+		issuers = append(issuers, inspected.cert)
+	}
+
+	var crlInfos []config.CRLInfo
+	var verified []*x509.RevocationList
+
+	for _, path := range in.crlPaths {
+		info := config.CRLInfo{Path: path}
+
+		crl, err := LoadCRL(path)
+		if err != nil {
+			info.Error = err.Error()
+			crlInfos = append(crlInfos, info)
+			continue
+		}
+
+		info.Issuer = crl.Issuer.String()
+		info.ThisUpdate = crl.ThisUpdate
+		info.NextUpdate = crl.NextUpdate
+		info.RevokedCount = len(crl.RevokedCertificateEntries)
+		info.Stale = IsStaleCRL(crl)
+		if crl.Number != nil {
+			info.Number = crl.Number.String()
+		}
+
+		if issuer := VerifyCRL(crl, issuers); issuer != nil {
+			info.Verified = true
+			info.VerifiedBy = issuer.Subject.String()
+			verified = append(verified, crl)
+		}
+		crlInfos = append(crlInfos, info)
+	}
+	for _, crl := range in.crls {
+		if VerifyCRL(crl, issuers) != nil {
+			verified = append(verified, crl)
+		}
+	}
+
+	var revocations []Revocation
+	if len(verified) == 0 {
+		return crlInfos, nil
+	}
+	for _, inspected := range in.certs {
+		result := &FIPSResult{IsCompliant: true}
+		CheckRevocation(result, inspected.cert, verified)
+		if !result.Revoked {
+			continue
+		}
+		revocations = append(revocations, Revocation{
+			Path:        inspected.path,
+			Index:       inspected.index,
+			Subject:     inspected.cert.Subject.String(),
+			Fingerprint: Fingerprint(inspected.cert),
+			Lines:       inspected.lines,
+			Finding:     result.Violations[0],
+		})
+	}
+	return crlInfos, revocations
+}
+
+// Marks the certificate as revoked
+func (r *Revocation) Apply(cert *config.CertificateInfo) {
+	cert.Revoked = true
+	cert.Compliant = false
+	cert.Violations = append(cert.Violations, r.Finding)
+}
+
+// The revocation as a finding located in the certificate's file
+func (r *Revocation) Located() config.LocatedFinding {
+	return config.LocatedFinding{
+		Finding:     r.Finding,
+		Path:        r.Path,
+		Subject:     r.Subject,
+		Fingerprint: r.Fingerprint,
+		Lines:       r.Lines,
+	}
+}
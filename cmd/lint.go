@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Serial numbers must carry at least 64 bits of CSPRNG output
+const minSerialBytes = 8
+
+var oidBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+
+// A check run against every parsed certificate
+type LintRule struct {
+	ID          string
+	Severity    string
+	Description string
+	Check       func(cert *x509.Certificate, thresholds config.Thresholds) []string
+}
+
+// The rules applied by LintCertificate, in reporting order
+var LintRules = []LintRule{
+	{
+		ID:          "basic-constraints-ca",
+		Severity:    config.SeverityError,
+		Description: "CA certificates must carry a critical basicConstraints extension with cA set",
+		Check:       lintBasicConstraints,
+	},
+	{
+		ID:          "key-usage-key-type",
+		Severity:    config.SeverityError,
+		Description: "keyUsage must be consistent with the public key type",
+		Check:       lintKeyUsage,
+	},
+	{
+		ID:          "ext-key-usage-consistency",
+		Severity:    config.SeverityWarning,
+		Description: "extKeyUsage purposes must be permitted by keyUsage",
+		Check:       lintExtKeyUsage,
+	},
+	{
+		ID:          "san-missing",
+		Severity:    config.SeverityError,
+		Description: "End-entity certificates must name their subject in a subjectAltName",
+		Check:       lintSANMissing,
+	},
+	{
+		ID:          "validity-period-too-long",
+		Severity:    config.SeverityError,
+		Description: "End-entity certificates must not be valid for longer than the maximum validity (398 days by default)",
+		Check:       lintValidityPeriod,
+	},
+	{
+		ID:          "expiring-soon",
+		Severity:    config.SeverityWarning,
+		Description: "Certificates should be renewed before they come within the expiry warning period",
+		Check:       lintExpiringSoon,
+	},
+	{
+		ID:          "serial-number-negative",
+		Severity:    config.SeverityError,
+		Description: "Serial numbers must be positive",
+		Check:       lintSerialNegative,
+	},
+	{
+		ID:          "serial-number-short",
+		Severity:    config.SeverityWarning,
+		Description: "Serial numbers should contain at least 64 bits",
+		Check:       lintSerialShort,
+	},
+	{
+		ID:          "critical-unknown-extension",
+		Severity:    config.SeverityError,
+		Description: "Certificates must not carry critical extensions that cannot be processed",
+		Check:       lintUnknownCriticalExtensions,
+	},
+}
+
+// Runs every lint rule against the certificate with the default thresholds
+func LintCertificate(cert *x509.Certificate) []config.Finding {
+	return DefaultChecker().Lint(cert)
+}
+
+// Runs every enabled lint rule against the certificate
+func (c *Checker) Lint(cert *x509.Certificate) []config.Finding {
+	findings := []config.Finding{}
+	for _, rule := range LintRules {
+		if !c.enabled(rule.ID) {
+			continue
+		}
+		for _, message := range rule.Check(cert, c.Thresholds) {
+			findings = append(findings, config.Finding{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				Message:  message,
+			})
+		}
+	}
+	return findings
+}
+
+func lintBasicConstraints(cert *x509.Certificate, _ config.Thresholds) []string {
+	var problems []string
+
+	if !cert.BasicConstraintsValid {
+		// cA can only be set in the extension, so CAs without it are told
+		// apart by keyUsage or, for version 1 certificates that can carry no
+		// extensions and that verifiers accept as CAs, by signing themselves
+		if cert.KeyUsage&x509.KeyUsageCertSign != 0 || cert.Version < 3 && isSelfSigned(cert) {
+			problems = append(problems, "CA certificate has no basicConstraints extension")
+		}
+		return problems
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign != 0 && !cert.IsCA {
+		problems = append(problems, "keyUsage permits certificate signing but cA is not set")
+	}
+	if !cert.IsCA {
+		return problems
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidBasicConstraints) && !ext.Critical {
+			problems = append(problems, "basicConstraints extension of a CA certificate is not critical")
+		}
+	}
+	if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		problems = append(problems, "CA certificate keyUsage does not permit certificate signing")
+	}
+	return problems
+}
+
+// Is the certificate signed with its own key?
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func lintKeyUsage(cert *x509.Certificate, _ config.Thresholds) []string {
+	var problems []string
+
+	switch cert.PublicKey.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		if cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+			problems = append(problems, fmt.Sprintf("keyUsage keyEncipherment is not possible with a %s key", cert.PublicKeyAlgorithm))
+		}
+		if cert.KeyUsage&x509.KeyUsageDataEncipherment != 0 {
+			problems = append(problems, fmt.Sprintf("keyUsage dataEncipherment is not possible with a %s key", cert.PublicKeyAlgorithm))
+		}
+		// Ed25519 keys can only sign; X25519 is a different key type
+		if cert.PublicKeyAlgorithm == x509.Ed25519 && cert.KeyUsage&x509.KeyUsageKeyAgreement != 0 {
+			problems = append(problems, "keyUsage keyAgreement is not possible with an Ed25519 key")
+		}
+	case *rsa.PublicKey:
+		if cert.KeyUsage&x509.KeyUsageKeyAgreement != 0 {
+			problems = append(problems, "keyUsage keyAgreement is not possible with an RSA key")
+		}
+	}
+	return problems
+}
+
+func lintExtKeyUsage(cert *x509.Certificate, _ config.Thresholds) []string {
+	// Without keyUsage every purpose is permitted
+	if cert.KeyUsage == 0 {
+		return nil
+	}
+
+	var problems []string
+	_, isRSA := cert.PublicKey.(*rsa.PublicKey)
+
+	for _, usage := range cert.ExtKeyUsage {
+		switch usage {
+		case x509.ExtKeyUsageServerAuth:
+			allowed := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement
+			if isRSA {
+				allowed = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+			}
+			if cert.KeyUsage&allowed == 0 {
+				problems = append(problems, "extKeyUsage serverAuth is not permitted by keyUsage")
+			}
+		case x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageOCSPSigning, x509.ExtKeyUsageTimeStamping:
+			if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+				problems = append(problems, fmt.Sprintf("extKeyUsage %s requires keyUsage digitalSignature", extKeyUsageName(usage)))
+			}
+		}
+	}
+	return problems
+}
+
+func lintSANMissing(cert *x509.Certificate, _ config.Thresholds) []string {
+	if cert.IsCA {
+		return nil
+	}
+	if len(cert.DNSNames) > 0 || len(cert.IPAddresses) > 0 || len(cert.EmailAddresses) > 0 || len(cert.URIs) > 0 {
+		return nil
+	}
+	if cert.Subject.CommonName != "" {
+		return []string{fmt.Sprintf("subject %q is only named in the common name", cert.Subject.CommonName)}
+	}
+	return []string{"certificate has no subjectAltName"}
+}
+
+func lintValidityPeriod(cert *x509.Certificate, thresholds config.Thresholds) []string {
+	if cert.IsCA || thresholds.MaxValidityDays == 0 {
+		return nil
+	}
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	if validity > time.Duration(thresholds.MaxValidityDays)*24*time.Hour {
+		return []string{fmt.Sprintf("validity period of %d days exceeds %d days", int(validity.Hours()/24), thresholds.MaxValidityDays)}
+	}
+	return nil
+}
+
+func lintExpiringSoon(cert *x509.Certificate, thresholds config.Thresholds) []string {
+	if thresholds.ExpiryWarningDays == 0 {
+		return nil
+	}
+	remaining := time.Until(cert.NotAfter)
+	if remaining > 0 && remaining < time.Duration(thresholds.ExpiryWarningDays)*24*time.Hour {
+		return []string{fmt.Sprintf("certificate expires in %d days (on %s)", int(remaining.Hours()/24), cert.NotAfter.Format("Jan 2, 2006"))}
+	}
+	return nil
+}
+
+func lintSerialNegative(cert *x509.Certificate, _ config.Thresholds) []string {
+	if cert.SerialNumber.Sign() < 0 {
+		return []string{fmt.Sprintf("serial number %s is negative", cert.SerialNumber)}
+	}
+	return nil
+}
+
+func lintSerialShort(cert *x509.Certificate, _ config.Thresholds) []string {
+	if n := len(cert.SerialNumber.Bytes()); n < minSerialBytes {
+		return []string{fmt.Sprintf("serial number is only %d bytes long", n)}
+	}
+	return nil
+}
+
+func lintUnknownCriticalExtensions(cert *x509.Certificate, _ config.Thresholds) []string {
+	if len(cert.UnhandledCriticalExtensions) == 0 {
+		return nil
+	}
+	oids := make([]string, len(cert.UnhandledCriticalExtensions))
+	for i, oid := range cert.UnhandledCriticalExtensions {
+		oids[i] = oid.String()
+	}
+	return []string{fmt.Sprintf("unrecognized critical extensions: %s", strings.Join(oids, ", "))}
+}
+
+func extKeyUsageName(usage x509.ExtKeyUsage) string {
+	switch usage {
+	case x509.ExtKeyUsageClientAuth:
+		return "clientAuth"
+	case x509.ExtKeyUsageCodeSigning:
+		return "codeSigning"
+	case x509.ExtKeyUsageOCSPSigning:
+		return "OCSPSigning"
+	case x509.ExtKeyUsageTimeStamping:
+		return "timeStamping"
+	default:
+		return fmt.Sprintf("%d", usage)
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func lintRuleIDs(cert *x509.Certificate) map[string]bool {
+	ids := make(map[string]bool)
+	for _, finding := range LintCertificate(cert) {
+		ids[finding.RuleID] = true
+	}
+	return ids
+}
+
+func TestLintCertificate_CleanLeaf(t *testing.T) {
+	ca := newTestCA(t, "Lint CA")
+	serial, _ := new(big.Int).SetString("7c3a9f0e21d84b56a1e2", 16)
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+
+	if findings := LintCertificate(leaf.cert); len(findings) != 0 {
+		t.Errorf("Expected no lint findings, got %+v", findings)
+	}
+}
+
+func TestLintCertificate_BrokenLeaf(t *testing.T) {
+	ca := newTestCA(t, "Lint CA")
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(5),
+		Subject:      pkix.Name{CommonName: "legacy.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(5 * 365 * 24 * time.Hour),
+		// ECDSA keys cannot encrypt, and clientAuth needs digitalSignature
+		KeyUsage:    x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 55555, 1}, Critical: true, Value: []byte{0x05, 0x00}},
+		},
+	}, ca)
+
+	ids := lintRuleIDs(leaf.cert)
+	for _, expected := range []string{
+		"san-missing",
+		"validity-period-too-long",
+		"serial-number-short",
+		"key-usage-key-type",
+		"ext-key-usage-consistency",
+		"critical-unknown-extension",
+	} {
+		if !ids[expected] {
+			t.Errorf("Expected lint %s to fire, got %v", expected, ids)
+		}
+	}
+	if ids["basic-constraints-ca"] {
+		t.Error("Did not expect basic-constraints-ca on a leaf certificate")
+	}
+}
+
+func TestLintCertificate_CertSignWithoutCA(t *testing.T) {
+	ca := newTestCA(t, "Lint CA")
+	leaf := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(0x1122334455667788),
+		Subject:               pkix.Name{CommonName: "not-a-ca.example.com"},
+		DNSNames:              []string{"not-a-ca.example.com"},
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}, ca)
+
+	if ids := lintRuleIDs(leaf.cert); !ids["basic-constraints-ca"] {
+		t.Errorf("Expected basic-constraints-ca to fire, got %v", ids)
+	}
+}
+
+// Creates a version 1 certificate, which crypto/x509 can not create, for
+// subject's key signed by issuer's
+func newV1Certificate(t *testing.T, serial int64, subject, issuer pkix.Name, key *ecdsa.PublicKey, signer *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+
+	spki, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	subjectDER, _ := asn1.Marshal(subject.ToRDNSequence())
+	issuerDER, _ := asn1.Marshal(issuer.ToRDNSequence())
+	algorithm := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}}
+	// Without a version field, which makes it version 1
+	tbs, err := asn1.Marshal(struct {
+		SerialNumber *big.Int
+		Signature    pkix.AlgorithmIdentifier
+		Issuer       asn1.RawValue
+		Validity     struct{ NotBefore, NotAfter time.Time }
+		Subject      asn1.RawValue
+		PublicKey    asn1.RawValue
+	}{
+		SerialNumber: big.NewInt(serial),
+		Signature:    algorithm,
+		Issuer:       asn1.RawValue{FullBytes: issuerDER},
+		Validity:     struct{ NotBefore, NotAfter time.Time }{time.Now().Add(-time.Hour).UTC(), time.Now().Add(365 * 24 * time.Hour).UTC()},
+		Subject:      asn1.RawValue{FullBytes: subjectDER},
+		PublicKey:    asn1.RawValue{FullBytes: spki},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal TBS certificate: %v", err)
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, signer, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	der, err := asn1.Marshal(struct {
+		TBS       asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}{asn1.RawValue{FullBytes: tbs}, algorithm, asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}})
+	if err != nil {
+		t.Fatalf("Failed to marshal certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestLintCertificate_V1CA(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caName := pkix.Name{CommonName: "Legacy Root"}
+	ca := newV1Certificate(t, 0x1122334455667788, caName, caName, &caKey.PublicKey, caKey)
+	if ca.Version != 1 {
+		t.Fatalf("Expected a version 1 certificate, got version %d", ca.Version)
+	}
+	if ids := lintRuleIDs(ca); !ids["basic-constraints-ca"] {
+		t.Errorf("Expected basic-constraints-ca to fire on a version 1 CA, got %v", ids)
+	}
+
+	// Version 1 leaves are not CAs
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf := newV1Certificate(t, 0x2233445566778899, pkix.Name{CommonName: "legacy.example.com"}, caName, &leafKey.PublicKey, caKey)
+	if ids := lintRuleIDs(leaf); ids["basic-constraints-ca"] {
+		t.Errorf("Did not expect basic-constraints-ca on a version 1 leaf, got %v", ids)
+	}
+}
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,24 +9,74 @@ import (
 	"org.gkh/findcert/config"
 )
 
-func ListCertificates(path string) ([]config.ExtensionResult, error) {
-	results := make([]config.ExtensionResult, len(config.CertExtensions))
-	for i, ext := range config.CertExtensions {
+// Which files a search collects
+type ListOptions struct {
+	// Extensions to collect, config.CertExtensions when empty
+	Extensions []string
+	// Glob patterns matched against each file or directory name and its path relative to the search root
+	Exclude []string
+	// Directories to leave out, such as those a resumed scan has already walked
+	SkipDir func(path string) bool
+	// Called once every entry below a directory has been walked, children
+	// before their parents. Directories still open when the walk stops early
+	// are not reported.
+	DirDone func(path string) error
+}
+
+// Collects the files below root with one of the extensions, grouped by extension
+func ListCertificates(root string, opts ListOptions) ([]config.ExtensionResult, error) {
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = config.CertExtensions
+	}
+
+	results := make([]config.ExtensionResult, len(extensions))
+	index := make(map[string]int, len(extensions))
+	for i, ext := range extensions {
 		results[i] = config.ExtensionResult{Type: ext}
+		index[ext] = i
 	}
 
-	// TODO(gkh) - provide a "skip" list
-	skipList := ""
+	err := WalkCertificates(context.Background(), root, opts, func(extension string, file config.FileInfo) error {
+		i := index[extension]
+		results[i].Files = append(results[i].Files, file)
+		return nil
+	})
+	return results, err
+}
 
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+// Calls fn for each file below root with one of the extensions, in walk
+// order, without collecting them. An error from fn stops the walk, as does
+// ctx being done.
+func WalkCertificates(ctx context.Context, root string, opts ListOptions, fn func(extension string, file config.FileInfo) error) error {
+	// The directories being walked, each inside the one before it
+	var open []string
+	closeDirs := func(path string) error {
+		for len(open) > 0 && !isWithin(open[len(open)-1], path) {
+			dir := open[len(open)-1]
+			open = open[:len(open)-1]
+			if opts.DirDone != nil {
+				if err := opts.DirDone(dir); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if info.IsDir() && info.Name() == skipList {
-			fmt.Printf("skipping a dir without errors: %+v \n", info.Name())
-			return filepath.SkipDir
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path != root && isExcluded(root, path, opts.Exclude) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
-		//fmt.Printf("Searching %q: %s\n", path, info.Name())
 
 		// Skip hidden directories and files
 		if strings.HasPrefix(filepath.Base(path), ".") {
@@ -36,21 +86,85 @@ func ListCertificates(path string) ([]config.ExtensionResult, error) {
 			return nil
 		}
 
-		// Check if file matches any certificate extension
-		for i, ext := range config.CertExtensions {
-			if strings.HasSuffix(strings.ToLower(path), ext) {
-				fileInfo := config.FileInfo{
-					Path:         path,
-					Size:         info.Size(),
-					ModifiedTime: info.ModTime(),
-				}
-				results[i].Files = append(results[i].Files, fileInfo)
-				break
+		// Walk finds paths in lexical order, so the open directories that do
+		// not hold this path have been walked completely
+		if err := closeDirs(path); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if opts.SkipDir != nil && opts.SkipDir(path) {
+				return filepath.SkipDir
 			}
+			open = append(open, path)
+			return nil
 		}
 
-		return nil
+		// Check if file matches any certificate extension
+		ext, ok := opts.Match(path)
+		if !ok {
+			return nil
+		}
+		return fn(ext, config.FileInfo{
+			Path:         path,
+			Size:         info.Size(),
+			ModifiedTime: info.ModTime(),
+			Mode:         info.Mode().String(),
+			Ownership:    NewOwnership(info),
+		})
 	})
+	if err != nil {
+		return err
+	}
+	return closeDirs("")
+}
 
-	return results, err
+// Is path below dir?
+func isWithin(dir, path string) bool {
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// Is path, found below root, left out of a search as hidden or excluded?
+func (o ListOptions) Skips(root, path string) bool {
+	if path == root {
+		return false
+	}
+	return strings.HasPrefix(filepath.Base(path), ".") || isExcluded(root, path, o.Exclude)
+}
+
+// The extension a search collects the file under, if any
+func (o ListOptions) Match(path string) (string, bool) {
+	extensions := o.Extensions
+	if len(extensions) == 0 {
+		extensions = config.CertExtensions
+	}
+	lower := strings.ToLower(path)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// Does any pattern match the name or the root-relative path?
+func isExcluded(root, path string, patterns []string) bool {
+	name := filepath.Base(path)
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		relPath = path
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"org.gkh/findcert/config"
+)
+
+func TestWalkCertificates_DirDoneAndSkipDir(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a/b/one.pem", "a/two.pem", "a.pem", "c/three.pem"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var done, files []string
+	opts := ListOptions{
+		SkipDir: func(path string) bool { return path == filepath.Join(root, "c") },
+		DirDone: func(path string) error {
+			rel, _ := filepath.Rel(root, path)
+			done = append(done, filepath.ToSlash(rel))
+			return nil
+		},
+	}
+	err := WalkCertificates(context.Background(), root, opts, func(extension string, file config.FileInfo) error {
+		rel, _ := filepath.Rel(root, file.Path)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkCertificates failed: %v", err)
+	}
+
+	if want := []string{"a/b/one.pem", "a/two.pem", "a.pem"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Expected files %v, got %v", want, files)
+	}
+	if want := []string{"a/b", "a", "."}; !reflect.DeepEqual(done, want) {
+		t.Errorf("Expected directories done in the order %v, got %v", want, done)
+	}
+}
@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// id-pkix-ocsp-nonce from RFC 6960 section 4.4.1
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// Tolerated clock difference between us and the OCSP responder
+const ocspClockSkew = 5 * time.Minute
+
+// Upper bound on the size of an OCSP response we are willing to read
+const maxOCSPResponseSize = 1 << 20
+
+// Where and how to obtain an OCSP response
+type OCSPOptions struct {
+	// Overrides the responder URL from the certificate's AIA extension
+	ResponderURL string
+	// A pre-fetched DER encoded OCSP response to use instead of the network
+	ResponseFile string
+	// Directory where verified responses are cached until their nextUpdate; empty disables caching
+	CacheDir string
+	// HTTP client used to query the responder; defaults to one with a 10 second timeout
+	Client *http.Client
+}
+
+// The verified revocation status of a certificate
+type OCSPResult struct {
+	Status           string
+	RevokedAt        time.Time
+	RevocationReason int
+	ProducedAt       time.Time
+	ThisUpdate       time.Time
+	NextUpdate       time.Time
+	Source           string
+	NonceVerified    bool
+}
+
+// Checks the revocation status of cert over OCSP, verifying the response against issuer
+func CheckOCSP(cert, issuer *x509.Certificate, opts OCSPOptions) (*OCSPResult, error) {
+	if issuer == nil {
+		return nil, errors.New("an issuer certificate is required for OCSP")
+	}
+
+	if opts.ResponseFile != "" {
+		der, err := os.ReadFile(opts.ResponseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCSP response file: %w", err)
+		}
+		resp, err := verifyOCSPResponse(der, cert, issuer, nil)
+		if err != nil {
+			return nil, err
+		}
+		return newOCSPResult(resp, opts.ResponseFile, false), nil
+	}
+
+	cachePath := ""
+	if opts.CacheDir != "" {
+		cachePath = filepath.Join(opts.CacheDir, ocspCacheKey(cert, issuer)+".der")
+		if der, err := os.ReadFile(cachePath); err == nil {
+			// A cached response that no longer verifies or is stale is simply refetched
+			if resp, err := verifyOCSPResponse(der, cert, issuer, nil); err == nil {
+				return newOCSPResult(resp, cachePath, false), nil
+			}
+		}
+	}
+
+	url := opts.ResponderURL
+	if url == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, errors.New("certificate has no OCSP responder URL")
+		}
+		url = cert.OCSPServer[0]
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate OCSP nonce: %w", err)
+	}
+
+	request, err := CreateOCSPRequest(cert, issuer, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := postOCSPRequest(opts.Client, url, request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := verifyOCSPResponse(der, cert, issuer, nonce)
+	if err != nil {
+		return nil, err
+	}
+	nonceVerified := hasOCSPNonce(resp, nonce)
+
+	if cachePath != "" && !resp.NextUpdate.IsZero() {
+		if err := os.MkdirAll(opts.CacheDir, 0700); err == nil {
+			_ = os.WriteFile(cachePath, der, 0600)
+		}
+	}
+
+	return newOCSPResult(resp, url, nonceVerified), nil
+}
+
+// Builds a DER encoded OCSP request for cert carrying the given nonce extension
+func CreateOCSPRequest(cert, issuer *x509.Certificate, nonce []byte) ([]byte, error) {
+	plain, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+	if len(nonce) == 0 {
+		return plain, nil
+	}
+
+	// x/crypto/ocsp cannot add request extensions, so rebuild it with the nonce
+	req, err := ocsp.ParseRequest(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	nonceValue, err := asn1.Marshal(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OCSP nonce: %w", err)
+	}
+
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: ocspTBSRequest{
+			RequestList: []ocspSingleRequest{{
+				Cert: ocspCertID{
+					HashAlgorithm: pkix.AlgorithmIdentifier{
+						Algorithm:  asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, // SHA-1, as CreateRequest uses
+						Parameters: asn1.NullRawValue,
+					},
+					NameHash:      req.IssuerNameHash,
+					IssuerKeyHash: req.IssuerKeyHash,
+					SerialNumber:  req.SerialNumber,
+				},
+			}},
+			Extensions: []pkix.Extension{{Id: oidOCSPNonce, Value: nonceValue}},
+		},
+	})
+}
+
+// Parses and verifies an OCSP response for cert. When nonce is set, a response
+// echoing a different nonce is rejected; responders that omit it are accepted
+func verifyOCSPResponse(der []byte, cert, issuer *x509.Certificate, nonce []byte) (*ocsp.Response, error) {
+	resp, err := ocsp.ParseResponseForCert(der, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+
+	// A delegated responder must be authorized to sign OCSP responses
+	if resp.Certificate != nil && !hasExtKeyUsage(resp.Certificate, x509.ExtKeyUsageOCSPSigning) {
+		return nil, errors.New("OCSP responder certificate is not authorized for OCSP signing")
+	}
+
+	now := time.Now()
+	if resp.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return nil, errors.New("OCSP response is not yet valid")
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now.Add(-ocspClockSkew)) {
+		return nil, errors.New("OCSP response is stale")
+	}
+
+	if nonce != nil {
+		if echoed := ocspNonces(resp); len(echoed) > 0 && !hasOCSPNonce(resp, nonce) {
+			return nil, errors.New("OCSP response nonce does not match the request")
+		}
+	}
+
+	return resp, nil
+}
+
+func postOCSPRequest(client *http.Client, url string, request []byte) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	httpResp, err := client.Post(url, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OCSP responder: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned %s", httpResp.Status)
+	}
+
+	der, err := io.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCSP response: %w", err)
+	}
+	return der, nil
+}
+
+// Does the response echo the nonce we sent?
+func hasOCSPNonce(resp *ocsp.Response, nonce []byte) bool {
+	expected, err := asn1.Marshal(nonce)
+	if err != nil {
+		return false
+	}
+	for _, value := range ocspNonces(resp) {
+		// Some responders echo the raw nonce instead of the OCTET STRING
+		if bytes.Equal(value, expected) || bytes.Equal(value, nonce) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collects nonce extension values from the responseExtensions and singleExtensions
+func ocspNonces(resp *ocsp.Response) [][]byte {
+	var nonces [][]byte
+
+	// x/crypto/ocsp only exposes singleExtensions, so parse the response data ourselves
+	var data ocspResponseData
+	if _, err := asn1.Unmarshal(resp.TBSResponseData, &data); err == nil {
+		for _, ext := range data.Extensions {
+			if ext.Id.Equal(oidOCSPNonce) {
+				nonces = append(nonces, ext.Value)
+			}
+		}
+	}
+	for _, ext := range resp.Extensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			nonces = append(nonces, ext.Value)
+		}
+	}
+	return nonces
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// The cache file name for a certificate, derived from its issuer key and serial
+func ocspCacheKey(cert, issuer *x509.Certificate) string {
+	h := sha256.New()
+	h.Write(issuer.RawSubjectPublicKeyInfo)
+	h.Write(cert.SerialNumber.Bytes())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newOCSPResult(resp *ocsp.Response, source string, nonceVerified bool) *OCSPResult {
+	result := &OCSPResult{
+		ProducedAt:    resp.ProducedAt,
+		ThisUpdate:    resp.ThisUpdate,
+		NextUpdate:    resp.NextUpdate,
+		Source:        source,
+		NonceVerified: nonceVerified,
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		result.Status = "good"
+	case ocsp.Revoked:
+		result.Status = "revoked"
+		result.RevokedAt = resp.RevokedAt
+		result.RevocationReason = resp.RevocationReason
+	default:
+		result.Status = "unknown"
+	}
+	return result
+}
+
+// Records a revoked OCSP status in the compliance result
+func ApplyOCSPResult(result *FIPSResult, ocspResult *OCSPResult) {
+	if ocspResult.Status != "revoked" {
+		return
+	}
+	result.Revoked = true
+	result.fail("revoked", fmt.Sprintf("Certificate was revoked on %s according to OCSP", ocspResult.RevokedAt.Format("Jan 2, 2006")))
+}
+
+// Returns the issuer of cert from the candidates, or nil if none signed it
+func FindIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range candidates {
+		if !bytes.Equal(cert.RawIssuer, candidate.RawSubject) {
+			continue
+		}
+		if cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// Prints the OCSP status check result
+func PrintOCSPResult(w io.Writer, result *OCSPResult) {
+	fmt.Fprintln(w, "\nOCSP Status:")
+	fmt.Fprintf(w, "Status: %s (from %s)\n", result.Status, result.Source)
+	if result.Status == "revoked" {
+		fmt.Fprintf(w, "Revoked on %s\n", result.RevokedAt.Format("Jan 2, 2006"))
+	}
+	fmt.Fprintf(w, "This update: %s\n", result.ThisUpdate.Format(time.RFC3339))
+	if !result.NextUpdate.IsZero() {
+		fmt.Fprintf(w, "Next update: %s\n", result.NextUpdate.Format(time.RFC3339))
+	}
+	if !result.NonceVerified {
+		fmt.Fprintln(w, "Nonce: not echoed by the responder (pre-signed or cached response)")
+	}
+}
+
+// The default location for cached OCSP responses
+func DefaultOCSPCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "findcert", "ocsp")
+}
+
+// ASN.1 structures from RFC 6960, used to add a nonce to requests and read responseExtensions
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []ocspSingleRequest
+	Extensions  []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []asn1.RawValue
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
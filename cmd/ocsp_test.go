@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Starts a local OCSP responder for ca; echo controls what it does with the request nonce
+func newTestResponder(t *testing.T, ca *testCert, status int, echo func(nonce []byte) []byte) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req ocspRequest
+		if _, err := asn1.Unmarshal(body, &req); err != nil || len(req.TBSRequest.RequestList) != 1 {
+			w.Write(ocsp.MalformedRequestErrorResponse)
+			return
+		}
+
+		template := ocsp.Response{
+			Status:       status,
+			SerialNumber: req.TBSRequest.RequestList[0].Cert.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Hour),
+		}
+		for _, ext := range req.TBSRequest.Extensions {
+			if ext.Id.Equal(oidOCSPNonce) && echo != nil {
+				template.ExtraExtensions = append(template.ExtraExtensions,
+					pkix.Extension{Id: oidOCSPNonce, Value: echo(ext.Value)})
+			}
+		}
+
+		der, err := ocsp.CreateResponse(ca.cert, ca.cert, template, ca.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+}
+
+func newTestLeafWithOCSP(t *testing.T, ca *testCert, url string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2024),
+		Subject:      pkix.Name{CommonName: "ocsp.example.com"},
+		OCSPServer:   []string{url},
+	}, ca)
+}
+
+func TestCheckOCSP_GoodResponseIsCached(t *testing.T) {
+	ca := newTestCA(t, "OCSP CA")
+	server := newTestResponder(t, ca, ocsp.Good, func(nonce []byte) []byte { return nonce })
+	leaf := newTestLeafWithOCSP(t, ca, server.URL)
+	cacheDir := t.TempDir()
+
+	result, err := CheckOCSP(leaf.cert, ca.cert, OCSPOptions{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("CheckOCSP failed: %v", err)
+	}
+	if result.Status != "good" {
+		t.Errorf("Expected status good, got %s", result.Status)
+	}
+	if !result.NonceVerified {
+		t.Error("Expected the echoed nonce to be verified")
+	}
+	if result.Source != server.URL {
+		t.Errorf("Expected source %s, got %s", server.URL, result.Source)
+	}
+
+	// The cached response must be used once the responder is gone
+	server.Close()
+
+	cached, err := CheckOCSP(leaf.cert, ca.cert, OCSPOptions{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("CheckOCSP from cache failed: %v", err)
+	}
+	if cached.Status != "good" || !strings.HasPrefix(cached.Source, cacheDir) {
+		t.Errorf("Expected a good status from the cache, got %s from %s", cached.Status, cached.Source)
+	}
+}
+
+func TestCheckOCSP_Revoked(t *testing.T) {
+	ca := newTestCA(t, "OCSP CA")
+	server := newTestResponder(t, ca, ocsp.Revoked, nil)
+	defer server.Close()
+	leaf := newTestLeafWithOCSP(t, ca, "http://ocsp.invalid")
+
+	result, err := CheckOCSP(leaf.cert, ca.cert, OCSPOptions{ResponderURL: server.URL})
+	if err != nil {
+		t.Fatalf("CheckOCSP failed: %v", err)
+	}
+	if result.Status != "revoked" {
+		t.Fatalf("Expected status revoked, got %s", result.Status)
+	}
+	if result.NonceVerified {
+		t.Error("Expected nonce not to be verified when the responder omits it")
+	}
+
+	fips := CheckCertificate(leaf.cert)
+	ApplyOCSPResult(fips, result)
+	if fips.IsCompliant || !fips.Revoked {
+		t.Errorf("Expected revoked certificate to be non-compliant, got %+v", fips)
+	}
+}
+
+func TestCheckOCSP_RejectsNonceMismatch(t *testing.T) {
+	ca := newTestCA(t, "OCSP CA")
+	server := newTestResponder(t, ca, ocsp.Good, func([]byte) []byte {
+		replayed, _ := asn1.Marshal([]byte("replayed response"))
+		return replayed
+	})
+	defer server.Close()
+	leaf := newTestLeafWithOCSP(t, ca, server.URL)
+
+	if _, err := CheckOCSP(leaf.cert, ca.cert, OCSPOptions{}); err == nil {
+		t.Fatal("Expected a nonce mismatch to be rejected")
+	}
+}
+
+func TestCheckOCSP_ResponseFile(t *testing.T) {
+	ca := newTestCA(t, "OCSP CA")
+	other := newTestCA(t, "Other CA")
+	leaf := newTestLeafWithOCSP(t, ca, "http://ocsp.invalid")
+
+	der, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: leaf.cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+	}, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create OCSP response: %v", err)
+	}
+	responseFile := filepath.Join(t.TempDir(), "leaf.ocsp")
+	if err := os.WriteFile(responseFile, der, 0644); err != nil {
+		t.Fatalf("Failed to write OCSP response: %v", err)
+	}
+
+	result, err := CheckOCSP(leaf.cert, ca.cert, OCSPOptions{ResponseFile: responseFile})
+	if err != nil {
+		t.Fatalf("CheckOCSP failed: %v", err)
+	}
+	if result.Status != "good" {
+		t.Errorf("Expected status good, got %s", result.Status)
+	}
+
+	// A response signed by someone else must not verify
+	if _, err := CheckOCSP(leaf.cert, other.cert, OCSPOptions{ResponseFile: responseFile}); err == nil {
+		t.Error("Expected a response from the wrong issuer to be rejected")
+	}
+}
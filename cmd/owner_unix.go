@@ -0,0 +1,20 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// Unix mode bits and owners are meaningful, so keys can be audited
+const canAuditPermissions = true
+
+// Reads the numeric owner and group from the file's stat data
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(stat.Uid), int(stat.Gid), true
+}
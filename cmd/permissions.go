@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"org.gkh/findcert/config"
+)
+
+// Extensions of key stores, which always hold private keys
+var keystoreExtensions = map[string]bool{
+	".jks":    true,
+	".bcfks":  true,
+	".pkcs12": true,
+	".p12":    true,
+	".pfx":    true,
+}
+
+// Files larger than this are not read when looking for private keys
+const maxKeyFileSize = 10 << 20
+
+// Reads the owner of a file and resolves the user and group names
+func NewOwnership(info os.FileInfo) *config.Ownership {
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+
+	ownership := &config.Ownership{UID: uid, GID: gid}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		ownership.Owner = u.Username
+	}
+	if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+		ownership.Group = g.Name
+	}
+	return ownership
+}
+
+// Does the file hold a private key, either as a key store or a PEM/DER key?
+func ContainsPrivateKey(path string) bool {
+	return findPrivateKey(path).PrivateKey
+}
+
+// Looks for a private key in the file, leaving the certificates out of the contents
+func findPrivateKey(path string) *FileContents {
+	contents := &FileContents{}
+	if keystoreExtensions[strings.ToLower(filepath.Ext(path))] {
+		contents.PrivateKey = true
+		return contents
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxKeyFileSize {
+		return contents
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return contents
+	}
+	privateKeyIn(contents, data)
+	return contents
+}
+
+func isPrivateKeyBlock(blockType string) bool {
+	return strings.HasSuffix(blockType, "PRIVATE KEY")
+}
+
+// Flags private keys and key stores that other users can read, that are owned
+// by someone outside allowedOwners, or that live in world-writable directories.
+// An empty allowedOwners list accepts any owner.
+func AuditPermissions(results []config.ExtensionResult, allowedOwners []string) {
+	checker := DefaultChecker()
+	checker.AllowedOwners = allowedOwners
+	checker.AuditPermissions(results)
+}
+
+// AuditPermissions with the checker's allowed owners and rules
+func (c *Checker) AuditPermissions(results []config.ExtensionResult) {
+	for i := range results {
+		for j := range results[i].Files {
+			c.AuditFile(&results[i].Files[j])
+		}
+	}
+}
+
+// Marks the file if it holds a private key and records its permission issues
+func (c *Checker) AuditFile(file *config.FileInfo) {
+	c.AuditContents(file, findPrivateKey(file.Path))
+}
+
+// AuditFile with the contents already read
+func (c *Checker) AuditContents(file *config.FileInfo, contents *FileContents) {
+	if !contents.PrivateKey {
+		return
+	}
+	file.PrivateKey = true
+	file.PrivateKeyLines = contents.PrivateKeyLines
+	file.PrivateKeyFingerprints = contents.PrivateKeyFingerprints
+	if !canAuditPermissions {
+		return
+	}
+	for _, finding := range auditKeyFile(file, c.AllowedOwners) {
+		if c.enabled(finding.RuleID) {
+			file.PermissionIssues = append(file.PermissionIssues, finding)
+		}
+	}
+}
+
+func auditKeyFile(file *config.FileInfo, allowedOwners []string) []config.Finding {
+	var findings []config.Finding
+
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return nil
+	}
+	perm := info.Mode().Perm()
+
+	if perm&0004 != 0 {
+		findings = append(findings, config.Finding{
+			RuleID:   "key-file-world-readable",
+			Severity: config.SeverityError,
+			Message:  fmt.Sprintf("private key file is readable by all users (mode %04o)", perm),
+		})
+	} else if perm&0040 != 0 {
+		findings = append(findings, config.Finding{
+			RuleID:   "key-file-group-readable",
+			Severity: config.SeverityWarning,
+			Message:  fmt.Sprintf("private key file is readable by its group (mode %04o)", perm),
+		})
+	}
+
+	if file.Ownership != nil && len(allowedOwners) > 0 && !isAllowedOwner(file.Ownership, allowedOwners) {
+		owner := file.Ownership.Owner
+		if owner == "" {
+			owner = strconv.Itoa(file.Ownership.UID)
+		}
+		findings = append(findings, config.Finding{
+			RuleID:   "key-file-owner",
+			Severity: config.SeverityError,
+			Message:  fmt.Sprintf("private key file is owned by unexpected user %s", owner),
+		})
+	}
+
+	dir := filepath.Dir(file.Path)
+	if dirInfo, err := os.Stat(dir); err == nil && dirInfo.Mode().Perm()&0002 != 0 {
+		message := fmt.Sprintf("private key file is in world-writable directory %s", dir)
+		if dirInfo.Mode()&os.ModeSticky != 0 {
+			message += " (sticky bit set, but any user can still add files beside it)"
+		}
+		findings = append(findings, config.Finding{
+			RuleID:   "key-dir-world-writable",
+			Severity: config.SeverityError,
+			Message:  message,
+		})
+	}
+
+	return findings
+}
+
+// Matches the owner by user name or numeric UID
+func isAllowedOwner(ownership *config.Ownership, allowedOwners []string) bool {
+	uid := strconv.Itoa(ownership.UID)
+	for _, allowed := range allowedOwners {
+		if allowed == ownership.Owner || allowed == uid {
+			return true
+		}
+	}
+	return false
+}
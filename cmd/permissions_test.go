@@ -0,0 +1,124 @@
+//go:build unix
+
+package cmd
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"org.gkh/findcert/config"
+)
+
+func TestAuditPermissions_FlagsExposedKeys(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ca := newTestCA(t, "Key CA")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	lockedDir := filepath.Join(tempDir, "locked")
+	openDir := filepath.Join(tempDir, "open")
+	for _, dir := range []string{lockedDir, openDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	// Chmod explicitly so the umask does not interfere
+	if err := os.Chmod(openDir, 0777); err != nil {
+		t.Fatalf("Failed to chmod %s: %v", openDir, err)
+	}
+
+	locked := filepath.Join(lockedDir, "server.pem")
+	exposed := filepath.Join(openDir, "server.pem")
+	certOnly := filepath.Join(openDir, "ca.crt")
+	writePEM(t, locked, "PRIVATE KEY", keyDER)
+	writePEM(t, exposed, "PRIVATE KEY", keyDER)
+	writePEM(t, certOnly, "CERTIFICATE", ca.cert.Raw)
+	os.Chmod(locked, 0600)
+	os.Chmod(exposed, 0644)
+
+	results, err := ListCertificates(tempDir, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	AuditPermissions(results, []string{"findcert-test-owner"})
+
+	issues := make(map[string]map[string]bool)
+	keys := make(map[string]bool)
+	for _, result := range results {
+		for _, file := range result.Files {
+			keys[file.Path] = file.PrivateKey
+			issues[file.Path] = make(map[string]bool)
+			for _, issue := range file.PermissionIssues {
+				issues[file.Path][issue.RuleID] = true
+			}
+			if file.Mode == "" || file.Ownership == nil {
+				t.Errorf("Expected mode and ownership to be recorded for %s", file.Path)
+			}
+		}
+	}
+
+	if !keys[locked] || !keys[exposed] || keys[certOnly] {
+		t.Errorf("Expected only the key files to be detected as private keys, got %v", keys)
+	}
+	if issues[locked]["key-file-world-readable"] || issues[locked]["key-dir-world-writable"] {
+		t.Errorf("Did not expect mode issues for the locked key, got %v", issues[locked])
+	}
+	for _, rule := range []string{"key-file-world-readable", "key-dir-world-writable", "key-file-owner"} {
+		if !issues[exposed][rule] {
+			t.Errorf("Expected %s for the exposed key, got %v", rule, issues[exposed])
+		}
+	}
+	if len(issues[certOnly]) != 0 {
+		t.Errorf("Did not expect issues for a certificate, got %v", issues[certOnly])
+	}
+}
+
+func TestAuditPermissions_DefaultSettingsWalkKeyFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ca := newTestCA(t, "Key CA")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	key := filepath.Join(tempDir, "server.key")
+	writePEM(t, key, "PRIVATE KEY", keyDER)
+	os.Chmod(key, 0644)
+	var exposed []string
+	for _, name := range []string{"client.p12", "client.pfx"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte("not parsed"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		os.Chmod(path, 0644)
+		exposed = append(exposed, path)
+	}
+	exposed = append(exposed, key)
+
+	results, err := ListCertificates(tempDir, ListOptions{Extensions: config.DefaultSettings().Extensions})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	AuditPermissions(results, nil)
+
+	readable := make(map[string]bool)
+	for _, result := range results {
+		for _, file := range result.Files {
+			for _, issue := range file.PermissionIssues {
+				if issue.RuleID == "key-file-world-readable" {
+					readable[file.Path] = true
+				}
+			}
+		}
+	}
+	for _, path := range exposed {
+		if !readable[path] {
+			t.Errorf("Expected key-file-world-readable for %s, got %v", path, readable)
+		}
+	}
+}
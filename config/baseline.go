@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Layout of suppression expiry dates
+const DateLayout = "2006-01-02"
+
+// Findings accepted as known risks, so they do not fail scans or show in reports
+type Baseline struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// Accepts the findings of one rule for a certificate, or for a file when no
+// fingerprint is given, until the end of the expiry date
+type Suppression struct {
+	RuleID      string `yaml:"rule_id"`
+	Fingerprint string `yaml:"fingerprint_sha256,omitempty"`
+	Path        string `yaml:"path,omitempty"`
+	// The certificate subject, for the reader only
+	Subject       string `yaml:"subject,omitempty"`
+	Justification string `yaml:"justification"`
+	// Date after which the finding is reported again, as YYYY-MM-DD
+	Expires string `yaml:"expires"`
+}
+
+// A finding a baseline suppressed, with the reason it was accepted
+type SuppressedFinding struct {
+	LocatedFinding
+	Justification string `json:"justification"`
+	Expires       string `json:"expires"`
+}
+
+// Reads and validates a baseline file
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var baseline Baseline
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&baseline); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	if err := baseline.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %w", path, err)
+	}
+	return &baseline, nil
+}
+
+// Writes the baseline as YAML
+func (b *Baseline) Write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(b); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Checks every suppression names a rule, what it applies to, a justification and a valid expiry date
+func (b *Baseline) Validate() error {
+	for i, s := range b.Suppressions {
+		if s.RuleID == "" {
+			return fmt.Errorf("suppression %d has no rule_id", i+1)
+		}
+		if s.Fingerprint == "" && s.Path == "" {
+			return fmt.Errorf("suppression %d of %s needs a fingerprint_sha256 or a path", i+1, s.RuleID)
+		}
+		if s.Justification == "" {
+			return fmt.Errorf("suppression %d of %s has no justification", i+1, s.RuleID)
+		}
+		if _, err := time.Parse(DateLayout, s.Expires); err != nil {
+			return fmt.Errorf("suppression %d of %s has an invalid expiry date %q, expected YYYY-MM-DD", i+1, s.RuleID, s.Expires)
+		}
+	}
+	return nil
+}
+
+// Whether the suppression no longer applies at now
+func (s *Suppression) Expired(now time.Time) bool {
+	expires, err := time.Parse(DateLayout, s.Expires)
+	if err != nil {
+		return true
+	}
+	// Valid through the whole expiry day
+	return !now.UTC().Before(expires.AddDate(0, 0, 1))
+}
+
+// Whether the suppression is about the finding, expired or not
+func (s *Suppression) Matches(finding LocatedFinding) bool {
+	if s.RuleID != finding.RuleID {
+		return false
+	}
+	if s.Fingerprint != "" {
+		return s.Fingerprint == finding.Fingerprint
+	}
+	return s.Path == finding.Path
+}
+
+// The unexpired suppression accepting the finding, or nil
+func (b *Baseline) Suppression(finding LocatedFinding, now time.Time) *Suppression {
+	if b == nil {
+		return nil
+	}
+	for i := range b.Suppressions {
+		s := &b.Suppressions[i]
+		if s.Matches(finding) && !s.Expired(now) {
+			return s
+		}
+	}
+	return nil
+}
+
+// The suppressions that have expired at now
+func (b *Baseline) Expired(now time.Time) []Suppression {
+	if b == nil {
+		return nil
+	}
+	var expired []Suppression
+	for i := range b.Suppressions {
+		if b.Suppressions[i].Expired(now) {
+			expired = append(expired, b.Suppressions[i])
+		}
+	}
+	return expired
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadBaseline_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"no target":        "suppressions:\n  - rule_id: revoked\n    justification: x\n    expires: 2030-01-01\n",
+		"no justification": "suppressions:\n  - rule_id: revoked\n    path: /a.pem\n    expires: 2030-01-01\n",
+		"bad date":         "suppressions:\n  - rule_id: revoked\n    path: /a.pem\n    justification: x\n    expires: 01/01/2030\n",
+		"unknown key":      "suppressions:\n  - rule: revoked\n",
+	} {
+		path := filepath.Join(t.TempDir(), "baseline.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write baseline: %v", err)
+		}
+		if _, err := LoadBaseline(path); err == nil {
+			t.Errorf("%s: expected the baseline to be rejected", name)
+		}
+	}
+}
+
+func TestBaseline_SuppressionExpiry(t *testing.T) {
+	baseline := &Baseline{Suppressions: []Suppression{
+		{RuleID: "validity-period", Fingerprint: "ab12", Justification: "Renewal booked", Expires: "2026-03-31"},
+		{RuleID: "key-file-world-readable", Path: "/etc/ssl/a.key", Justification: "Test host", Expires: "2026-03-31"},
+	}}
+	cert := LocatedFinding{Finding: Finding{RuleID: "validity-period"}, Path: "/any/where.pem", Fingerprint: "ab12"}
+	file := LocatedFinding{Finding: Finding{RuleID: "key-file-world-readable"}, Path: "/etc/ssl/a.key"}
+
+	lastDay := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	for _, finding := range []LocatedFinding{cert, file} {
+		if baseline.Suppression(finding, lastDay) == nil {
+			t.Errorf("Expected %s to be suppressed through its expiry date", finding.RuleID)
+		}
+	}
+	if baseline.Suppression(LocatedFinding{Finding: Finding{RuleID: "revoked"}, Fingerprint: "ab12"}, lastDay) != nil {
+		t.Error("Expected another rule not to be suppressed")
+	}
+
+	after := lastDay.Add(2 * time.Hour)
+	if baseline.Suppression(cert, after) != nil {
+		t.Error("Expected an expired suppression to no longer apply")
+	}
+	if expired := baseline.Expired(after); len(expired) != 2 {
+		t.Errorf("Expected both suppressions to have expired, got %d", len(expired))
+	}
+
+	var nilBaseline *Baseline
+	if nilBaseline.Suppression(cert, lastDay) != nil || len(nilBaseline.Expired(after)) != 0 {
+		t.Error("Expected a nil baseline to suppress nothing")
+	}
+}
+
+func TestBaseline_WriteRoundTrip(t *testing.T) {
+	baseline := &Baseline{Suppressions: []Suppression{
+		{RuleID: "fips-public-key", Fingerprint: "cd34", Subject: "CN=legacy", Justification: "Appliance", Expires: "2027-01-01"},
+	}}
+	var out strings.Builder
+	if err := baseline.Write(&out); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.yaml")
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		t.Fatalf("Failed to write baseline: %v", err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v\n%s", err, out.String())
+	}
+	if len(loaded.Suppressions) != 1 || loaded.Suppressions[0] != baseline.Suppressions[0] {
+		t.Errorf("Expected the suppression back, got %+v", loaded.Suppressions)
+	}
+}
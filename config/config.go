@@ -2,14 +2,20 @@ package config
 
 import "time"
 
+// Extensions walked when the settings name none. Keys and key stores are
+// included so that their permissions are audited.
 var CertExtensions = []string{
 	".pem",
 	".der",
 	".crt",
 	".cer",
+	".key",
 	".pkcs12",
+	".p12",
+	".pfx",
 	".jks",
 	".bcfks",
+	".crl",
 }
 
 // Certificate file information
@@ -17,18 +23,167 @@ type FileInfo struct {
 	Path         string    `json:"path"`
 	Size         int64     `json:"size"`
 	ModifiedTime time.Time `json:"modified_time"`
+	// Permission bits as ls shows them, e.g. -rw-r-----
+	Mode      string     `json:"mode,omitempty"`
+	Ownership *Ownership `json:"ownership,omitempty"`
+	// Whether the file holds a private key or is a key store
+	PrivateKey      bool        `json:"private_key,omitempty"`
+	PrivateKeyLines []LineRange `json:"private_key_lines,omitempty"`
+	// SHA-256 fingerprints of the public halves of the private keys, to match
+	// them to a certificate's public_key_fingerprint_sha256. Keys that can not
+	// be parsed, such as encrypted ones and key stores, have none.
+	PrivateKeyFingerprints []string          `json:"private_key_fingerprints_sha256,omitempty"`
+	PermissionIssues       []Finding         `json:"permission_issues,omitempty"`
+	Certificates           []CertificateInfo `json:"certificates,omitempty"`
+	// Findings in the file a baseline accepted
+	Suppressed []SuppressedFinding `json:"suppressed,omitempty"`
+	// Why the file could not be read, empty when it was
+	Error string `json:"error,omitempty"`
+}
+
+// The owner of a file, where the platform records one
+type Ownership struct {
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// A certificate parsed from a file and its compliance result
+type CertificateInfo struct {
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+	// Hexadecimal, without leading zeros
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	PublicKeyAlgorithm string    `json:"public_key_algorithm"`
+	// Key size in bits
+	PublicKeySize  int    `json:"public_key_size,omitempty"`
+	PublicKeyCurve string `json:"public_key_curve,omitempty"`
+	// SHA-256 of the DER encoded SubjectPublicKeyInfo
+	PublicKeyFingerprint string   `json:"public_key_fingerprint_sha256,omitempty"`
+	IsCA                 bool     `json:"is_ca"`
+	DNSNames             []string `json:"dns_names,omitempty"`
+	IPAddresses          []string `json:"ip_addresses,omitempty"`
+	// SHA-256 of the DER encoded certificate, in hexadecimal
+	Fingerprint string `json:"fingerprint_sha256"`
+	// Whether the certificate passed every FIPS 140-3 and validity rule
+	Compliant bool `json:"compliant"`
+	Revoked   bool `json:"revoked"`
+	// The compliance rules the certificate fails
+	Violations []Finding `json:"violations,omitempty"`
+	// Findings of the X.509 lint rules
+	Lints []Finding `json:"lints,omitempty"`
+	// Where the PEM block sits in its file, nil for DER files
+	Lines *LineRange `json:"lines,omitempty"`
+}
+
+// Summarizes the compliance of a certificate as compliant, non-compliant or revoked
+func (c *CertificateInfo) ComplianceStatus() string {
+	switch {
+	case c.Revoked:
+		return "revoked"
+	case c.Compliant:
+		return "compliant"
+	default:
+		return "non-compliant"
+	}
+}
+
+// The first and last line, counted from 1, of a PEM block in a file
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// The certificates found that are valid for an expected host name
+type HostCoverage struct {
+	Host string `json:"host"`
+	// Files holding a valid leaf certificate for the host
+	Paths []string `json:"paths"`
+}
+
+// Severity levels for findings
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNotice  = "notice"
+)
+
+// A problem found by a rule, identified by the rule's ID
+type Finding struct {
+	RuleID string `json:"rule_id"`
+	// One of error, warning or notice
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// A certificate revocation list found during the search
+type CRLInfo struct {
+	Path         string    `json:"path"`
+	Issuer       string    `json:"issuer,omitempty"`
+	Number       string    `json:"number,omitempty"`
+	ThisUpdate   time.Time `json:"this_update"`
+	NextUpdate   time.Time `json:"next_update"`
+	RevokedCount int       `json:"revoked_count"`
+	// Whether next_update has passed
+	Stale bool `json:"stale"`
+	// Whether a certificate found in the scan signed the CRL
+	Verified   bool   `json:"verified"`
+	VerifiedBy string `json:"verified_by,omitempty"`
+	Error      string `json:"error,omitempty"`
 }
 
 // The files found for each extension
 type ExtensionResult struct {
+	// The extension, with its leading dot
 	Type  string     `json:"type"`
 	Files []FileInfo `json:"files"`
 }
 
-// Tthe complete search results
+// A finding together with the file and certificate it was found in
+type LocatedFinding struct {
+	Finding
+	Path        string     `json:"path,omitempty"`
+	Subject     string     `json:"subject,omitempty"`
+	Fingerprint string     `json:"fingerprint_sha256,omitempty"`
+	Lines       *LineRange `json:"lines,omitempty"`
+}
+
+// Version of the results format. The minor version changes when fields are
+// added, the major version when fields are removed, renamed or change type.
+const SchemaVersion = "1.6"
+
+// The complete search results
 type SearchResult struct {
-	SearchPath string            `json:"search_path"`
-	TotalFiles int               `json:"total_files"`
-	Results    []ExtensionResult `json:"results"`
-	SearchTime time.Time         `json:"search_time"`
+	// Version of the format the results are written in, see SchemaVersion
+	SchemaVersion string            `json:"schema_version"`
+	SearchPath    string            `json:"search_path"`
+	TotalFiles    int               `json:"total_files"`
+	Results       []ExtensionResult `json:"results"`
+	CRLs          []CRLInfo         `json:"crls,omitempty"`
+	Hosts         []HostCoverage    `json:"host_coverage,omitempty"`
+	// CRL and host findings a baseline accepted; file findings are kept with their file
+	Suppressed []SuppressedFinding `json:"suppressed,omitempty"`
+	// Rules the scan's policy turned off, whose findings reports leave out
+	DisabledRules []string `json:"disabled_rules,omitempty"`
+	// When the search finished
+	SearchTime time.Time `json:"search_time"`
+	// How long the search took, in seconds
+	Duration float64 `json:"duration_seconds,omitempty"`
+	// Whether the search was interrupted or timed out before it had seen
+	// every file, so the results only cover part of the tree
+	Incomplete bool `json:"incomplete,omitempty"`
+	// How often the scan's cache held the contents of a file, when it used one
+	Cache *CacheStats `json:"cache,omitempty"`
+}
+
+// The files a scan took from its cache and the files it had to read
+type CacheStats struct {
+	// Files unchanged since they were cached
+	Hits int `json:"hits"`
+	// Files read because they were new or had changed
+	Misses int `json:"misses"`
 }
@@ -0,0 +1,59 @@
+package config
+
+import "time"
+
+// The changes between two scans of the same host
+type ResultDiff struct {
+	Old               DiffSource         `json:"old"`
+	New               DiffSource         `json:"new"`
+	Added             []DiffCertificate  `json:"added"`
+	Removed           []DiffCertificate  `json:"removed"`
+	Renewed           []Renewal          `json:"renewed"`
+	Moved             []Move             `json:"moved"`
+	ComplianceChanged []ComplianceChange `json:"compliance_changed"`
+}
+
+// One of the result files compared
+type DiffSource struct {
+	File       string    `json:"file"`
+	SearchPath string    `json:"search_path"`
+	SearchTime time.Time `json:"search_time"`
+}
+
+// A certificate and the files it was found in
+type DiffCertificate struct {
+	Subject      string    `json:"subject"`
+	SerialNumber string    `json:"serial_number"`
+	NotAfter     time.Time `json:"not_after"`
+	Fingerprint  string    `json:"fingerprint_sha256"`
+	Compliance   string    `json:"compliance"`
+	Paths        []string  `json:"paths"`
+}
+
+// A certificate replaced by one with the same subject and SANs but a new serial
+type Renewal struct {
+	Old DiffCertificate `json:"old"`
+	New DiffCertificate `json:"new"`
+}
+
+// A certificate found in other files than before
+type Move struct {
+	Subject     string   `json:"subject"`
+	Fingerprint string   `json:"fingerprint_sha256"`
+	OldPaths    []string `json:"old_paths"`
+	NewPaths    []string `json:"new_paths"`
+}
+
+// A certificate whose compliance status is not what it was
+type ComplianceChange struct {
+	DiffCertificate
+	OldCompliance string `json:"old_compliance"`
+	// The rules the certificate fails now
+	Violations []Finding `json:"violations,omitempty"`
+}
+
+// Whether the scans differ at all
+func (d *ResultDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renewed) == 0 &&
+		len(d.Moved) == 0 && len(d.ComplianceChanged) == 0
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Name of the configuration file looked up in the default locations
+const SettingsFileName = "findcert.yaml"
+
+// Scan defaults and policies, loaded from findcert.yaml and FINDCERT_* variables
+type Settings struct {
+	Extensions []string   `yaml:"extensions"`
+	Exclude    []string   `yaml:"exclude"`
+	Output     Output     `yaml:"output"`
+	Cache      Cache      `yaml:"cache"`
+	Thresholds Thresholds `yaml:"thresholds"`
+	Policy     Policy     `yaml:"policy"`
+	// Where to tell about the findings once a scan is done
+	Notify []Notification `yaml:"notify"`
+
+	// The file the settings were read from, if any
+	Source string `yaml:"-"`
+}
+
+// Where and how scan results are written
+type Output struct {
+	// Defaults to results.<extension of the format>
+	File   string `yaml:"file"`
+	Format string `yaml:"format"`
+	// SQLite inventory each scan is also recorded in, none when empty
+	Database string `yaml:"database"`
+}
+
+// Where scans remember the contents of the files they read, so that a rescan
+// only reads the files that changed
+type Cache struct {
+	// The cache file, no cache when empty
+	File string `yaml:"file"`
+	// Also compare the SHA-256 of every file, for file systems whose
+	// modification times can not be trusted
+	Hash bool `yaml:"hash"`
+}
+
+// Limits applied when checking certificates
+type Thresholds struct {
+	MinRSABits        int `yaml:"min_rsa_bits"`
+	MaxValidityDays   int `yaml:"max_validity_days"`
+	ExpiryWarningDays int `yaml:"expiry_warning_days"`
+}
+
+// What a scan expects of the files it finds
+type Policy struct {
+	AllowedOwners []string `yaml:"allowed_owners"`
+	Hosts         []string `yaml:"hosts"`
+	DisabledRules []string `yaml:"disabled_rules"`
+	// Baseline file of accepted findings
+	Baseline string `yaml:"baseline"`
+	// Lowest severity that makes the scan fail: none, notice, warning or error
+	FailOn string `yaml:"fail_on"`
+}
+
+// A command or webhook told about the findings of a scan once it is done.
+// Exactly one of Command and Webhook is set.
+type Notification struct {
+	// Shell command run with the notification on its standard input
+	Command string `yaml:"command"`
+	// URL the notification is POSTed to
+	Webhook string `yaml:"webhook"`
+	// Payload format: json (the default), slack or teams
+	Template string `yaml:"template"`
+	// Go text/template file rendering the payload instead of Template
+	TemplateFile string `yaml:"template_file"`
+	// Lowest severity of the findings told about: notice (the default), warning or error
+	Severity string `yaml:"severity"`
+	// Only notify when at least this many findings are left after the filters, 1 when zero
+	MinFindings int `yaml:"min_findings"`
+	// Only tell about the findings of these rules, every rule when empty
+	Rules []string `yaml:"rules"`
+	// How often to try before giving up, 3 when zero
+	Attempts int `yaml:"attempts"`
+}
+
+// Names the notification target in messages. Only the scheme and host of a
+// webhook are shown, as chat webhook URLs carry their token in the path.
+func (n *Notification) Target() string {
+	if n.Webhook != "" {
+		if target, err := url.Parse(n.Webhook); err == nil {
+			return "webhook " + target.Scheme + "://" + target.Host
+		}
+		return "webhook"
+	}
+	return n.Command
+}
+
+// The settings used when no configuration file or variables are present
+func DefaultSettings() Settings {
+	return Settings{
+		Extensions: append([]string(nil), CertExtensions...),
+		Output: Output{
+			Format: "json",
+		},
+		Thresholds: Thresholds{
+			MinRSABits:        2048,
+			MaxValidityDays:   398,
+			ExpiryWarningDays: 30,
+		},
+		Policy: Policy{
+			FailOn: "none",
+		},
+	}
+}
+
+// The locations searched for findcert.yaml when no path is given, in order
+func SettingsPaths() []string {
+	var paths []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "findcert", SettingsFileName))
+	}
+
+	return append(paths, filepath.Join("/etc/findcert", SettingsFileName))
+}
+
+// Loads the settings from path, or from the first default location that
+// exists when path is empty, and then applies FINDCERT_* environment variables.
+// Values missing from the file keep their defaults.
+func LoadSettings(path string) (Settings, error) {
+	settings := DefaultSettings()
+
+	if path == "" {
+		path = os.Getenv("FINDCERT_CONFIG")
+	}
+
+	if path != "" {
+		if err := settings.readFile(path); err != nil {
+			return settings, err
+		}
+	} else {
+		for _, candidate := range SettingsPaths() {
+			err := settings.readFile(candidate)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return settings, err
+			}
+			break
+		}
+	}
+
+	if err := settings.applyEnv(os.Getenv); err != nil {
+		return settings, err
+	}
+	return settings, settings.Validate()
+}
+
+func (s *Settings) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file decodes to io.EOF and leaves the defaults in place
+	if err := decoder.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse configuration %s: %w", path, err)
+	}
+	s.Source = path
+	return nil
+}
+
+// Overrides settings from FINDCERT_* variables, read through getenv
+func (s *Settings) applyEnv(getenv func(string) string) error {
+	lists := map[string]*[]string{
+		"FINDCERT_EXTENSIONS":     &s.Extensions,
+		"FINDCERT_EXCLUDE":        &s.Exclude,
+		"FINDCERT_ALLOWED_OWNERS": &s.Policy.AllowedOwners,
+		"FINDCERT_HOSTS":          &s.Policy.Hosts,
+		"FINDCERT_DISABLED_RULES": &s.Policy.DisabledRules,
+	}
+	for name, target := range lists {
+		if value := getenv(name); value != "" {
+			*target = SplitList(value)
+		}
+	}
+
+	strs := map[string]*string{
+		"FINDCERT_OUTPUT":   &s.Output.File,
+		"FINDCERT_FORMAT":   &s.Output.Format,
+		"FINDCERT_FAIL_ON":  &s.Policy.FailOn,
+		"FINDCERT_BASELINE": &s.Policy.Baseline,
+		"FINDCERT_DATABASE": &s.Output.Database,
+		"FINDCERT_CACHE":    &s.Cache.File,
+	}
+	for name, target := range strs {
+		if value := getenv(name); value != "" {
+			*target = value
+		}
+	}
+
+	ints := map[string]*int{
+		"FINDCERT_MIN_RSA_BITS":        &s.Thresholds.MinRSABits,
+		"FINDCERT_MAX_VALIDITY_DAYS":   &s.Thresholds.MaxValidityDays,
+		"FINDCERT_EXPIRY_WARNING_DAYS": &s.Thresholds.ExpiryWarningDays,
+	}
+	for name, target := range ints {
+		value := getenv(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target = n
+	}
+	return nil
+}
+
+// Checks that the settings can be used for a scan
+func (s *Settings) Validate() error {
+	if len(s.Extensions) == 0 {
+		return errors.New("at least one extension must be configured")
+	}
+	for i, ext := range s.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		s.Extensions[i] = ext
+	}
+	for _, pattern := range s.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	if _, ok := severityRank[s.Policy.FailOn]; !ok && s.Policy.FailOn != "none" {
+		return fmt.Errorf("invalid fail_on %q: must be none, notice, warning or error", s.Policy.FailOn)
+	}
+	if s.Thresholds.MinRSABits < 0 || s.Thresholds.MaxValidityDays < 0 || s.Thresholds.ExpiryWarningDays < 0 {
+		return errors.New("thresholds must not be negative")
+	}
+	for i := range s.Notify {
+		if err := s.Notify[i].validate(); err != nil {
+			return fmt.Errorf("invalid notification %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (n *Notification) validate() error {
+	if (n.Command == "") == (n.Webhook == "") {
+		return errors.New("exactly one of command and webhook must be set")
+	}
+	if n.Webhook != "" {
+		target, err := url.Parse(n.Webhook)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return errors.New("webhook must be an http or https URL")
+		}
+	}
+	switch n.Template {
+	case "", "json", "slack", "teams":
+	default:
+		return fmt.Errorf("unknown template %q: must be json, slack or teams", n.Template)
+	}
+	if n.Template != "" && n.TemplateFile != "" {
+		return errors.New("template and template_file can not both be set")
+	}
+	if _, ok := severityRank[n.Severity]; !ok && n.Severity != "" {
+		return fmt.Errorf("invalid severity %q: must be notice, warning or error", n.Severity)
+	}
+	if n.MinFindings < 0 || n.Attempts < 0 {
+		return errors.New("min_findings and attempts must not be negative")
+	}
+	return nil
+}
+
+var severityRank = map[string]int{
+	SeverityNotice:  1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+}
+
+// Is the severity at or above the threshold? A threshold of none never matches.
+func SeverityAtLeast(severity, threshold string) bool {
+	min, ok := severityRank[threshold]
+	if !ok {
+		return false
+	}
+	return severityRank[severity] >= min
+}
+
+// Splits a comma separated value, dropping empty entries
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
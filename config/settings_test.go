@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), SettingsFileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write settings: %v", err)
+	}
+	return path
+}
+
+func TestLoadSettings_FileOverridesDefaults(t *testing.T) {
+	t.Setenv("FINDCERT_CONFIG", "")
+	path := writeSettings(t, `
+extensions: [PEM, crt]
+exclude: ["vendor", "*.bak"]
+thresholds:
+  min_rsa_bits: 3072
+policy:
+  fail_on: warning
+  disabled_rules: [serial-number-short]
+`)
+
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if !reflect.DeepEqual(settings.Extensions, []string{".pem", ".crt"}) {
+		t.Errorf("Expected normalized extensions, got %v", settings.Extensions)
+	}
+	if settings.Thresholds.MinRSABits != 3072 || settings.Thresholds.MaxValidityDays != 398 {
+		t.Errorf("Expected file thresholds merged with defaults, got %+v", settings.Thresholds)
+	}
+	if settings.Output.Format != "json" || settings.Policy.FailOn != "warning" {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+	if settings.Source != path {
+		t.Errorf("Expected source %s, got %s", path, settings.Source)
+	}
+}
+
+func TestLoadSettings_EnvironmentOverridesFile(t *testing.T) {
+	path := writeSettings(t, "output:\n  file: file.json\npolicy:\n  fail_on: warning\n")
+	t.Setenv("FINDCERT_CONFIG", path)
+	t.Setenv("FINDCERT_FAIL_ON", "error")
+	t.Setenv("FINDCERT_HOSTS", "a.example.com, b.example.com")
+
+	settings, err := LoadSettings("")
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if settings.Output.File != "file.json" {
+		t.Errorf("Expected output from the file, got %s", settings.Output.File)
+	}
+	if settings.Policy.FailOn != "error" {
+		t.Errorf("Expected fail_on from the environment, got %s", settings.Policy.FailOn)
+	}
+	if !reflect.DeepEqual(settings.Policy.Hosts, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("Expected hosts from the environment, got %v", settings.Policy.Hosts)
+	}
+}
+
+func TestLoadSettings_DefaultLocation(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("FINDCERT_CONFIG", "")
+	if err := os.MkdirAll(filepath.Join(configHome, "findcert"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(configHome, "findcert", SettingsFileName)
+	if err := os.WriteFile(path, []byte("output:\n  file: xdg.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := LoadSettings("")
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if settings.Source != path || settings.Output.File != "xdg.json" {
+		t.Errorf("Expected settings from %s, got %+v", path, settings)
+	}
+}
+
+func TestLoadSettings_RejectsInvalidFiles(t *testing.T) {
+	t.Setenv("FINDCERT_CONFIG", "")
+	for name, content := range map[string]string{
+		"unknown field":   "extentions: [.pem]\n",
+		"invalid fail_on": "policy:\n  fail_on: sometimes\n",
+		"bad pattern":     "exclude: [\"[\"]\n",
+		"notify nowhere":  "notify:\n  - severity: error\n",
+		"notify both":     "notify:\n  - command: cat\n    webhook: https://example.com/hook\n",
+		"notify bad url":  "notify:\n  - webhook: ftp://example.com/hook\n",
+		"notify template": "notify:\n  - webhook: https://example.com/hook\n    template: discord\n",
+	} {
+		if _, err := LoadSettings(writeSettings(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSeverityAtLeast(t *testing.T) {
+	if !SeverityAtLeast(SeverityError, SeverityWarning) {
+		t.Error("Expected error to be at least warning")
+	}
+	if SeverityAtLeast(SeverityNotice, SeverityWarning) {
+		t.Error("Expected notice to be below warning")
+	}
+	if SeverityAtLeast(SeverityError, "none") {
+		t.Error("Expected none to never match")
+	}
+}
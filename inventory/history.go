@@ -0,0 +1,164 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A scan recorded in the inventory
+type Scan struct {
+	ID         int64     `json:"id"`
+	Host       string    `json:"host"`
+	SearchPath string    `json:"search_path"`
+	StartedAt  time.Time `json:"started_at"`
+	// Zero for a scan that never finished
+	FinishedAt time.Time `json:"finished_at"`
+	TotalFiles int       `json:"total_files"`
+	Findings   int       `json:"findings"`
+}
+
+// What the inventory knows about one certificate across all scans
+type CertificateHistory struct {
+	Fingerprint  string    `json:"fingerprint_sha256"`
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotAfter     time.Time `json:"not_after"`
+	// The hosts and paths the certificate was found at in its last scan
+	Hosts []string `json:"hosts"`
+	Paths []string `json:"paths"`
+	// The first and last scan the certificate was found in
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Scans     int       `json:"scans"`
+	// Compliance in the last scan it was found in
+	Compliant bool `json:"compliant"`
+	// Since when the certificate has been non-compliant in every scan it was
+	// found in, nil when it is compliant
+	NonCompliantSince *time.Time `json:"non_compliant_since,omitempty"`
+}
+
+// The scans recorded, newest first
+func (d *DB) Scans() ([]Scan, error) {
+	rows, err := d.db.Query(`SELECT s.id, h.name, s.search_path, s.started_at, COALESCE(s.finished_at, ''), s.total_files,
+			(SELECT COUNT(*) FROM findings f WHERE f.scan_id = s.id AND f.suppressed = 0)
+		FROM scans s JOIN hosts h ON h.id = s.host_id
+		ORDER BY s.started_at DESC, s.id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []Scan
+	for rows.Next() {
+		var scan Scan
+		var started, finished string
+		if err := rows.Scan(&scan.ID, &scan.Host, &scan.SearchPath, &started, &finished, &scan.TotalFiles, &scan.Findings); err != nil {
+			return nil, err
+		}
+		scan.StartedAt = parseTime(started)
+		scan.FinishedAt = parseTime(finished)
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+// The history of every certificate found by a finished scan, the longest
+// non-compliant first, then by expiry
+func (d *DB) Certificates() ([]CertificateHistory, error) {
+	rows, err := d.db.Query(`SELECT c.fingerprint_sha256, c.subject, c.issuer, c.serial_number, c.not_after,
+			s.id, s.finished_at, h.name, f.path, c.compliant
+		FROM certificates c
+		JOIN scans s ON s.id = c.scan_id
+		JOIN hosts h ON h.id = s.host_id
+		JOIN files f ON f.id = c.file_id
+		WHERE s.finished_at IS NOT NULL
+		ORDER BY c.fingerprint_sha256, s.finished_at, s.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	var histories []CertificateHistory
+	var current *CertificateHistory
+	var lastScan int64
+	var lastSeen time.Time
+	// Updates the non-compliant streak once every copy in a scan has been seen
+	endScan := func() {
+		if current == nil {
+			return
+		}
+		switch {
+		case current.Compliant:
+			current.NonCompliantSince = nil
+		case current.NonCompliantSince == nil:
+			since := lastSeen
+			current.NonCompliantSince = &since
+		}
+	}
+
+	for rows.Next() {
+		var fingerprint, subject, issuer, serial, notAfter, finished, host, path string
+		var scanID int64
+		var compliant bool
+		if err := rows.Scan(&fingerprint, &subject, &issuer, &serial, &notAfter, &scanID, &finished, &host, &path, &compliant); err != nil {
+			return nil, err
+		}
+		seen := parseTime(finished)
+
+		if current == nil || current.Fingerprint != fingerprint {
+			endScan()
+			histories = append(histories, CertificateHistory{Fingerprint: fingerprint, FirstSeen: seen})
+			current = &histories[len(histories)-1]
+			lastScan = 0
+		}
+		if scanID != lastScan {
+			if lastScan != 0 {
+				endScan()
+			}
+			// Each scan gives a new picture of where the certificate is
+			current.Scans++
+			current.Hosts, current.Paths = nil, nil
+			current.Compliant = true
+			lastScan, lastSeen = scanID, seen
+		}
+		current.Subject, current.Issuer, current.SerialNumber = subject, issuer, serial
+		current.NotAfter = parseTime(notAfter)
+		current.LastSeen = seen
+		current.Hosts = appendUnique(current.Hosts, host)
+		current.Paths = appendUnique(current.Paths, path)
+		// A copy that fails in any file makes the certificate non-compliant in the scan
+		current.Compliant = current.Compliant && compliant
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	endScan()
+
+	sort.SliceStable(histories, func(i, j int) bool {
+		a, b := histories[i].NonCompliantSince, histories[j].NonCompliantSince
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil && !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		return histories[i].NotAfter.Before(histories[j].NotAfter)
+	})
+	return histories, nil
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if existing == item {
+			return list
+		}
+	}
+	return append(list, item)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(timeLayout, value)
+	return t
+}
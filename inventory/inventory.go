@@ -0,0 +1,309 @@
+// Package inventory keeps the results of every scan in a SQLite database, so
+// the history of each certificate can be queried across scans and hosts.
+package inventory
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+
+	// Pure Go SQLite driver, so findcert still builds with CGO_ENABLED=0
+	_ "modernc.org/sqlite"
+)
+
+// Times are stored as fixed width UTC text so they sort in SQL
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// The statements that bring a database from one version to the next; the
+// version is kept in PRAGMA user_version
+var migrations = []string{
+	`CREATE TABLE hosts (
+		id   INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE
+	);
+	CREATE TABLE scans (
+		id               INTEGER PRIMARY KEY,
+		host_id          INTEGER NOT NULL REFERENCES hosts(id),
+		search_path      TEXT NOT NULL,
+		schema_version   TEXT NOT NULL DEFAULT '',
+		started_at       TEXT NOT NULL,
+		finished_at      TEXT,
+		duration_seconds REAL,
+		total_files      INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE files (
+		id            INTEGER PRIMARY KEY,
+		scan_id       INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+		path          TEXT NOT NULL,
+		extension     TEXT NOT NULL,
+		size          INTEGER NOT NULL,
+		modified_time TEXT NOT NULL,
+		mode          TEXT,
+		owner         TEXT,
+		group_name    TEXT,
+		private_key   INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX files_scan ON files(scan_id);
+	CREATE TABLE certificates (
+		id                   INTEGER PRIMARY KEY,
+		scan_id              INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+		file_id              INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+		fingerprint_sha256   TEXT NOT NULL,
+		subject              TEXT NOT NULL,
+		issuer               TEXT NOT NULL,
+		serial_number        TEXT NOT NULL,
+		not_before           TEXT NOT NULL,
+		not_after            TEXT NOT NULL,
+		signature_algorithm  TEXT NOT NULL,
+		public_key_algorithm TEXT NOT NULL,
+		public_key_size      INTEGER,
+		is_ca                INTEGER NOT NULL,
+		compliant            INTEGER NOT NULL,
+		revoked              INTEGER NOT NULL,
+		start_line           INTEGER,
+		end_line             INTEGER
+	);
+	CREATE INDEX certificates_fingerprint ON certificates(fingerprint_sha256);
+	CREATE INDEX certificates_scan ON certificates(scan_id);
+	CREATE TABLE findings (
+		id                 INTEGER PRIMARY KEY,
+		scan_id            INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+		file_id            INTEGER REFERENCES files(id) ON DELETE CASCADE,
+		certificate_id     INTEGER REFERENCES certificates(id) ON DELETE CASCADE,
+		rule_id            TEXT NOT NULL,
+		severity           TEXT NOT NULL,
+		message            TEXT NOT NULL,
+		path               TEXT,
+		fingerprint_sha256 TEXT,
+		suppressed         INTEGER NOT NULL DEFAULT 0,
+		justification      TEXT,
+		expires            TEXT
+	);
+	CREATE INDEX findings_scan ON findings(scan_id);`,
+}
+
+// A findcert inventory database
+type DB struct {
+	db *sql.DB
+}
+
+// Opens the database at path, creating it or bringing its tables up to date
+func Open(path string) (*DB, error) {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory %s: %w", path, err)
+	}
+	// One connection keeps the pragmas and avoids writers locking each other out
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare inventory %s: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("database version %d is newer than this findcert supports (%d)", version, len(migrations))
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Records a completed search made on host
+func (d *DB) Record(host string, result *config.SearchResult) error {
+	started := result.SearchTime.Add(-time.Duration(result.Duration * float64(time.Second)))
+	recorder, err := d.BeginScan(host, result.SearchPath, started)
+	if err != nil {
+		return err
+	}
+	for _, extResult := range result.Results {
+		for i := range extResult.Files {
+			file := &extResult.Files[i]
+			if err := recorder.File(extResult.Type, file); err != nil {
+				recorder.Abort()
+				return err
+			}
+			for _, finding := range cmd.ReportedFileFindings(result, file) {
+				if err := recorder.Finding(finding); err != nil {
+					recorder.Abort()
+					return err
+				}
+			}
+		}
+	}
+	for _, finding := range cmd.ReportedResultFindings(result) {
+		if err := recorder.Finding(finding); err != nil {
+			recorder.Abort()
+			return err
+		}
+	}
+	return recorder.Finish(result)
+}
+
+// Writes one scan to the database as it runs, in a single transaction that
+// Finish commits. It receives the same calls as a report.Stream.
+type Recorder struct {
+	tx     *sql.Tx
+	scanID int64
+	files  map[string]int64
+	certs  map[[2]string]int64
+}
+
+// Starts recording a scan of searchPath on host
+func (d *DB) BeginScan(host, searchPath string, started time.Time) (*Recorder, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start inventory transaction: %w", err)
+	}
+	r := &Recorder{tx: tx, files: make(map[string]int64), certs: make(map[[2]string]int64)}
+
+	if _, err := tx.Exec("INSERT INTO hosts (name) VALUES (?) ON CONFLICT (name) DO NOTHING", host); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to record host: %w", err)
+	}
+	res, err := tx.Exec(`INSERT INTO scans (host_id, search_path, started_at)
+		VALUES ((SELECT id FROM hosts WHERE name = ?), ?, ?)`, host, searchPath, formatTime(started))
+	if err == nil {
+		r.scanID, err = res.LastInsertId()
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to record scan: %w", err)
+	}
+	return r, nil
+}
+
+// Records a file, its certificates and the findings a baseline suppressed in it
+func (r *Recorder) File(extension string, file *config.FileInfo) error {
+	var owner, group any
+	if file.Ownership != nil {
+		owner, group = file.Ownership.Owner, file.Ownership.Group
+	}
+	res, err := r.tx.Exec(`INSERT INTO files (scan_id, path, extension, size, modified_time, mode, owner, group_name, private_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.scanID, file.Path, extension, file.Size, formatTime(file.ModifiedTime), file.Mode, owner, group, file.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to record file %s: %w", file.Path, err)
+	}
+	fileID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.files[file.Path] = fileID
+
+	for _, cert := range file.Certificates {
+		var startLine, endLine any
+		if cert.Lines != nil {
+			startLine, endLine = cert.Lines.Start, cert.Lines.End
+		}
+		res, err := r.tx.Exec(`INSERT INTO certificates (scan_id, file_id, fingerprint_sha256, subject, issuer,
+			serial_number, not_before, not_after, signature_algorithm, public_key_algorithm, public_key_size,
+			is_ca, compliant, revoked, start_line, end_line)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.scanID, fileID, cert.Fingerprint, cert.Subject, cert.Issuer, cert.SerialNumber,
+			formatTime(cert.NotBefore), formatTime(cert.NotAfter), cert.SignatureAlgorithm, cert.PublicKeyAlgorithm,
+			cert.PublicKeySize, cert.IsCA, cert.Compliant, cert.Revoked, startLine, endLine)
+		if err != nil {
+			return fmt.Errorf("failed to record certificate %s: %w", cert.Subject, err)
+		}
+		certID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		r.certs[[2]string{file.Path, cert.Fingerprint}] = certID
+	}
+
+	for _, suppressed := range file.Suppressed {
+		if err := r.finding(suppressed.LocatedFinding, &suppressed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records a finding, linked to its file and certificate when they were recorded
+func (r *Recorder) Finding(finding config.LocatedFinding) error {
+	return r.finding(finding, nil)
+}
+
+func (r *Recorder) finding(finding config.LocatedFinding, suppressed *config.SuppressedFinding) error {
+	var fileID, certID, justification, expires any
+	if id, ok := r.files[finding.Path]; ok {
+		fileID = id
+	}
+	if id, ok := r.certs[[2]string{finding.Path, finding.Fingerprint}]; ok && finding.Fingerprint != "" {
+		certID = id
+	}
+	if suppressed != nil {
+		justification, expires = suppressed.Justification, suppressed.Expires
+	}
+	_, err := r.tx.Exec(`INSERT INTO findings (scan_id, file_id, certificate_id, rule_id, severity, message,
+		path, fingerprint_sha256, suppressed, justification, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.scanID, fileID, certID, finding.RuleID, finding.Severity, finding.Message,
+		finding.Path, finding.Fingerprint, suppressed != nil, justification, expires)
+	if err != nil {
+		return fmt.Errorf("failed to record finding %s: %w", finding.RuleID, err)
+	}
+	return nil
+}
+
+// Completes the scan with the totals of the result and commits it
+func (r *Recorder) Finish(result *config.SearchResult) error {
+	for i := range result.Suppressed {
+		if err := r.finding(result.Suppressed[i].LocatedFinding, &result.Suppressed[i]); err != nil {
+			r.Abort()
+			return err
+		}
+	}
+	_, err := r.tx.Exec(`UPDATE scans SET schema_version = ?, finished_at = ?, duration_seconds = ?, total_files = ?
+		WHERE id = ?`, result.SchemaVersion, formatTime(result.SearchTime), result.Duration, result.TotalFiles, r.scanID)
+	if err != nil {
+		r.Abort()
+		return fmt.Errorf("failed to record scan: %w", err)
+	}
+	if err := r.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit scan: %w", err)
+	}
+	return nil
+}
+
+// Discards everything recorded for the scan
+func (r *Recorder) Abort() error {
+	return r.tx.Rollback()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
@@ -0,0 +1,113 @@
+package inventory
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func testResult(searchTime time.Time, compliant bool, paths ...string) *config.SearchResult {
+	result := &config.SearchResult{
+		SchemaVersion: config.SchemaVersion,
+		SearchPath:    "/etc/ssl",
+		TotalFiles:    len(paths),
+		SearchTime:    searchTime,
+		Duration:      2,
+	}
+	var files []config.FileInfo
+	for _, path := range paths {
+		cert := config.CertificateInfo{
+			Subject:     "CN=www.example.com",
+			Issuer:      "CN=Example CA",
+			NotAfter:    time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+			Fingerprint: "ab12",
+			Compliant:   compliant,
+		}
+		if !compliant {
+			cert.Violations = []config.Finding{{RuleID: "fips-public-key", Severity: config.SeverityError, Message: "RSA key size 1024 bits is below minimum 2048"}}
+		}
+		files = append(files, config.FileInfo{Path: path, Certificates: []config.CertificateInfo{cert}})
+	}
+	result.Results = []config.ExtensionResult{{Type: ".pem", Files: files}}
+	return result
+}
+
+func TestRecordAndHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	day := func(n int) time.Time { return time.Date(2026, 9, n, 12, 0, 0, 0, time.UTC) }
+	for _, result := range []*config.SearchResult{
+		testResult(day(1), true, "/etc/ssl/www.pem"),
+		testResult(day(2), false, "/etc/ssl/www.pem"),
+		testResult(day(3), false, "/etc/ssl/www.pem", "/srv/www.pem"),
+	} {
+		if err := db.Record("web1", result); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	db.Close()
+
+	// Reopening an existing database keeps its scans
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	scans, err := db.Scans()
+	if err != nil {
+		t.Fatalf("Scans failed: %v", err)
+	}
+	if len(scans) != 3 || scans[0].Host != "web1" || !scans[0].FinishedAt.Equal(day(3)) || scans[0].Findings != 2 {
+		t.Errorf("Expected 3 scans, the newest first with 2 findings, got %+v", scans)
+	}
+	if !scans[2].StartedAt.Equal(day(1).Add(-2 * time.Second)) {
+		t.Errorf("Expected the start time from the duration, got %s", scans[2].StartedAt)
+	}
+
+	certs, err := db.Certificates()
+	if err != nil {
+		t.Fatalf("Certificates failed: %v", err)
+	}
+	if len(certs) != 1 {
+		t.Fatalf("Expected one certificate, got %+v", certs)
+	}
+	cert := certs[0]
+	if !cert.FirstSeen.Equal(day(1)) || !cert.LastSeen.Equal(day(3)) || cert.Scans != 3 {
+		t.Errorf("Unexpected first/last seen %s, %s in %d scans", cert.FirstSeen, cert.LastSeen, cert.Scans)
+	}
+	if cert.Compliant || cert.NonCompliantSince == nil || !cert.NonCompliantSince.Equal(day(2)) {
+		t.Errorf("Expected non-compliant since the second scan, got %+v", cert.NonCompliantSince)
+	}
+	if len(cert.Paths) != 2 {
+		t.Errorf("Expected both paths of the last scan, got %v", cert.Paths)
+	}
+}
+
+func TestRecorder_AbortDiscardsScan(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	recorder, err := db.BeginScan("web1", "/etc/ssl", time.Now())
+	if err != nil {
+		t.Fatalf("BeginScan failed: %v", err)
+	}
+	file := testResult(time.Now(), true, "/etc/ssl/www.pem").Results[0].Files[0]
+	if err := recorder.File(".pem", &file); err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	recorder.Abort()
+
+	if scans, _ := db.Scans(); len(scans) != 0 {
+		t.Errorf("Expected an aborted scan to leave nothing, got %+v", scans)
+	}
+}
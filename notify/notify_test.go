@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExec_PayloadOnStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	var stdout bytes.Buffer
+	err := Exec(context.Background(), `echo "$FINDCERT_EVENT"; cat`, []byte(`{"type":"added"}`), []string{"FINDCERT_EVENT=added"}, &stdout, io.Discard)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if got := stdout.String(); got != "added\n{\"type\":\"added\"}" {
+		t.Errorf("Expected the environment and payload, got %q", got)
+	}
+
+	if err := Exec(context.Background(), "exit 3", nil, nil, io.Discard, io.Discard); err == nil {
+		t.Error("Expected a failing command to be an error")
+	}
+}
+
+func TestPost(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON content type, got %q", r.Header.Get("Content-Type"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if strings.Contains(body, "fail") {
+			http.Error(w, "rejected", http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	if err := Post(context.Background(), server.Client(), server.URL, []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if body != `{"ok":true}` {
+		t.Errorf("Expected the payload to be posted, got %q", body)
+	}
+	err := Post(context.Background(), server.Client(), server.URL, []byte(`{"fail":true}`))
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("Expected the status to be reported, got %v", err)
+	}
+}
+
+func TestPost_HidesURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL + "/services/secret-token"
+	server.Close()
+
+	err := Post(context.Background(), http.DefaultClient, url, []byte(`{}`))
+	if err == nil {
+		t.Fatal("Expected posting to a closed server to fail")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected the URL to be left out, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// What a notification tells about a scan
+type Report struct {
+	// The machine the scan ran on
+	Host       string    `json:"host"`
+	SearchPath string    `json:"search_path"`
+	SearchTime time.Time `json:"search_time"`
+	TotalFiles int       `json:"total_files"`
+	Incomplete bool      `json:"incomplete,omitempty"`
+	// The number of findings of each severity
+	Counts   map[string]int          `json:"counts"`
+	Findings []config.LocatedFinding `json:"findings"`
+}
+
+// The report of a scan with the findings that are neither disabled nor accepted
+func NewReport(host string, result *config.SearchResult, findings []config.LocatedFinding) *Report {
+	report := &Report{
+		Host:       host,
+		SearchPath: result.SearchPath,
+		SearchTime: result.SearchTime,
+		TotalFiles: result.TotalFiles,
+		Incomplete: result.Incomplete,
+	}
+	report.setFindings(findings)
+	return report
+}
+
+func (r *Report) setFindings(findings []config.LocatedFinding) {
+	r.Findings = findings
+	if r.Findings == nil {
+		r.Findings = []config.LocatedFinding{}
+	}
+	r.Counts = map[string]int{
+		config.SeverityError:   0,
+		config.SeverityWarning: 0,
+		config.SeverityNotice:  0,
+	}
+	for _, finding := range findings {
+		r.Counts[finding.Severity]++
+	}
+}
+
+// The report with only the findings the notification asks about
+func (r *Report) filter(n *config.Notification) *Report {
+	severity := n.Severity
+	if severity == "" {
+		severity = config.SeverityNotice
+	}
+	filtered := *r
+	var findings []config.LocatedFinding
+	for _, finding := range r.Findings {
+		if config.SeverityAtLeast(finding.Severity, severity) &&
+			(len(n.Rules) == 0 || slices.Contains(n.Rules, finding.RuleID)) {
+			findings = append(findings, finding)
+		}
+	}
+	filtered.setFindings(findings)
+	return &filtered
+}
+
+const (
+	defaultAttempts = 3
+	defaultBackoff  = time.Second
+)
+
+// Sends reports to commands and webhooks, trying again when they fail
+type Notifier struct {
+	Client *http.Client
+	// Where the output of commands goes
+	Stdout io.Writer
+	Stderr io.Writer
+	// The wait before the second attempt, doubled for every attempt after it;
+	// one second when zero
+	Backoff time.Duration
+}
+
+// Sends the report to the notification's command or webhook, unless too few
+// of its findings pass the notification's filters. Returns whether it was sent.
+func (n *Notifier) Send(ctx context.Context, notification *config.Notification, report *Report) (bool, error) {
+	report = report.filter(notification)
+	if len(report.Findings) < max(notification.MinFindings, 1) {
+		return false, nil
+	}
+	payload, err := render(notification, report)
+	if err != nil {
+		return false, err
+	}
+
+	attempts := notification.Attempts
+	if attempts == 0 {
+		attempts = defaultAttempts
+	}
+	wait := n.Backoff
+	if wait == 0 {
+		wait = defaultBackoff
+	}
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		err := n.deliver(ctx, notification, report, payload)
+		if err == nil {
+			return true, nil
+		}
+		errs = append(errs, err)
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return false, errors.Join(append(errs, ctx.Err())...)
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	return false, fmt.Errorf("gave up after %d attempts: %w", attempts, errors.Join(errs...))
+}
+
+func (n *Notifier) deliver(ctx context.Context, notification *config.Notification, report *Report, payload []byte) error {
+	if notification.Webhook != "" {
+		client := n.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		return Post(ctx, client, notification.Webhook, payload)
+	}
+	env := []string{
+		"FINDCERT_FINDINGS=" + strconv.Itoa(len(report.Findings)),
+		"FINDCERT_SEARCH_PATH=" + report.SearchPath,
+	}
+	return Exec(ctx, notification.Command, payload, env, n.writer(n.Stdout), n.writer(n.Stderr))
+}
+
+func (n *Notifier) writer(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// Renders the payload in the notification's template
+func render(notification *config.Notification, report *Report) ([]byte, error) {
+	if notification.TemplateFile != "" {
+		return renderFile(notification.TemplateFile, report)
+	}
+	switch notification.Template {
+	case "slack":
+		return json.Marshal(slackPayload(report))
+	case "teams":
+		return json.Marshal(teamsPayload(report))
+	default:
+		return json.Marshal(report)
+	}
+}
+
+// Renders the report with a Go text/template, which can use the json
+// function to quote values
+func renderFile(path string, report *Report) ([]byte, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification template: %w", err)
+	}
+	tmpl, err := template.New(path).Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("failed to render notification template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func testReport() *Report {
+	return NewReport("host1", &config.SearchResult{SearchPath: "/etc/ssl", TotalFiles: 4}, []config.LocatedFinding{
+		{Path: "/etc/ssl/a.pem", Finding: config.Finding{RuleID: "cert-expired", Severity: config.SeverityError, Message: "expired"}},
+		{Path: "/etc/ssl/b.pem", Finding: config.Finding{RuleID: "rsa-key-too-small", Severity: config.SeverityWarning, Message: "small key"}},
+		{Path: "/etc/ssl/c.pem", Finding: config.Finding{RuleID: "self-signed", Severity: config.SeverityNotice, Message: "self-signed"}},
+	})
+}
+
+// Records the bodies posted to it, failing the first failures requests
+type recordingServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func newRecordingServer(t *testing.T, failures int) *recordingServer {
+	s := &recordingServer{failures: failures}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.bodies = append(s.bodies, string(data))
+		if s.failures > 0 {
+			s.failures--
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *recordingServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestNotifier_WebhookTemplates(t *testing.T) {
+	server := newRecordingServer(t, 0)
+	notifier := &Notifier{Client: server.Client()}
+	for _, template := range []string{"json", "slack", "teams"} {
+		sent, err := notifier.Send(context.Background(), &config.Notification{Webhook: server.URL, Template: template}, testReport())
+		if err != nil || !sent {
+			t.Fatalf("%s: expected the notification to be sent, got %v, %v", template, sent, err)
+		}
+	}
+	bodies := server.requests()
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(bodies))
+	}
+
+	var report Report
+	if err := json.Unmarshal([]byte(bodies[0]), &report); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if report.Host != "host1" || len(report.Findings) != 3 || report.Counts[config.SeverityError] != 1 {
+		t.Errorf("Unexpected JSON payload %+v", report)
+	}
+
+	var slack struct {
+		Text   string `json:"text"`
+		Blocks []any  `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(bodies[1]), &slack); err != nil {
+		t.Fatalf("Invalid Slack payload: %v", err)
+	}
+	if !strings.Contains(slack.Text, "3 finding(s) in /etc/ssl on host1") || !strings.Contains(slack.Text, "cert-expired") || len(slack.Blocks) != 1 {
+		t.Errorf("Unexpected Slack payload %s", bodies[1])
+	}
+
+	var teams struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type string           `json:"type"`
+				Body []map[string]any `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal([]byte(bodies[2]), &teams); err != nil {
+		t.Fatalf("Invalid Teams payload: %v", err)
+	}
+	if teams.Type != "message" || len(teams.Attachments) != 1 ||
+		teams.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" ||
+		teams.Attachments[0].Content.Type != "AdaptiveCard" || len(teams.Attachments[0].Content.Body) != 5 {
+		t.Errorf("Unexpected Teams payload %s", bodies[2])
+	}
+}
+
+func TestNotifier_Filters(t *testing.T) {
+	server := newRecordingServer(t, 0)
+	notifier := &Notifier{Client: server.Client()}
+
+	sent, err := notifier.Send(context.Background(), &config.Notification{Webhook: server.URL, Severity: config.SeverityWarning}, testReport())
+	if err != nil || !sent {
+		t.Fatalf("Expected the notification to be sent, got %v, %v", sent, err)
+	}
+	var report Report
+	if err := json.Unmarshal([]byte(server.requests()[0]), &report); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if len(report.Findings) != 2 || report.Counts[config.SeverityNotice] != 0 {
+		t.Errorf("Expected only warnings and errors, got %+v", report)
+	}
+
+	for name, notification := range map[string]*config.Notification{
+		"threshold": {Webhook: server.URL, Severity: config.SeverityWarning, MinFindings: 3},
+		"rules":     {Webhook: server.URL, Rules: []string{"weak-signature"}},
+	} {
+		sent, err := notifier.Send(context.Background(), notification, testReport())
+		if err != nil || sent {
+			t.Errorf("%s: expected no notification, got %v, %v", name, sent, err)
+		}
+	}
+	if len(server.requests()) != 1 {
+		t.Errorf("Expected filtered notifications to not be posted, got %d requests", len(server.requests()))
+	}
+}
+
+func TestNotifier_Retries(t *testing.T) {
+	server := newRecordingServer(t, 2)
+	notifier := &Notifier{Client: server.Client(), Backoff: time.Millisecond}
+
+	sent, err := notifier.Send(context.Background(), &config.Notification{Webhook: server.URL}, testReport())
+	if err != nil || !sent {
+		t.Fatalf("Expected the third attempt to succeed, got %v, %v", sent, err)
+	}
+	if len(server.requests()) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", len(server.requests()))
+	}
+
+	server.mu.Lock()
+	server.failures = 5
+	server.mu.Unlock()
+	_, err = notifier.Send(context.Background(), &config.Notification{Webhook: server.URL, Attempts: 2}, testReport())
+	if err == nil || !strings.Contains(err.Error(), "gave up after 2 attempts") {
+		t.Errorf("Expected to give up, got %v", err)
+	}
+	if len(server.requests()) != 5 {
+		t.Errorf("Expected 2 more attempts, got %d", len(server.requests())-3)
+	}
+}
+
+func TestNotifier_CommandAndTemplateFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "payload.tmpl")
+	template := `{"summary": {{json .SearchPath}}, "errors": {{index .Counts "error"}}}`
+	if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	notifier := &Notifier{Stdout: &stdout}
+	notification := &config.Notification{Command: `echo "$FINDCERT_FINDINGS"; cat`, TemplateFile: path}
+	if sent, err := notifier.Send(context.Background(), notification, testReport()); err != nil || !sent {
+		t.Fatalf("Expected the command to run, got %v, %v", sent, err)
+	}
+	if got := stdout.String(); got != "3\n{\"summary\": \"/etc/ssl\", \"errors\": 1}" {
+		t.Errorf("Unexpected command output %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"org.gkh/findcert/config"
+)
+
+// Chat messages list at most this many findings and count the rest
+const maxListedFindings = 20
+
+// One line headline of the report
+func (r *Report) title() string {
+	title := fmt.Sprintf("findcert: %d finding(s) in %s on %s", len(r.Findings), r.SearchPath, r.Host)
+	if r.Incomplete {
+		title += " (incomplete scan)"
+	}
+	return title
+}
+
+// A line for each finding listed, and one counting those left out
+func (r *Report) findingLines() []string {
+	var lines []string
+	for i, finding := range r.Findings {
+		if i == maxListedFindings {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(r.Findings)-i))
+			break
+		}
+		lines = append(lines, findingLine(finding))
+	}
+	return lines
+}
+
+func findingLine(finding config.LocatedFinding) string {
+	line := fmt.Sprintf("[%s] %s: %s", finding.Severity, finding.RuleID, finding.Message)
+	if finding.Subject != "" {
+		line += " - " + finding.Subject
+	}
+	if finding.Path != "" {
+		line += " (" + finding.Path + ")"
+	}
+	return line
+}
+
+// A message for Slack incoming webhooks, which Mattermost and Rocket.Chat accept as well
+func slackPayload(r *Report) map[string]any {
+	text := "*" + r.title() + "*\n" + strings.Join(prefixed("• ", r.findingLines()), "\n")
+	return map[string]any{
+		"text": text,
+		"blocks": []map[string]any{
+			{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}},
+		},
+	}
+}
+
+// An Adaptive Card message for Microsoft Teams webhooks
+func teamsPayload(r *Report) map[string]any {
+	body := []map[string]any{
+		{"type": "TextBlock", "text": r.title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+		{"type": "FactSet", "facts": []map[string]any{
+			{"title": "Errors", "value": fmt.Sprint(r.Counts[config.SeverityError])},
+			{"title": "Warnings", "value": fmt.Sprint(r.Counts[config.SeverityWarning])},
+			{"title": "Notices", "value": fmt.Sprint(r.Counts[config.SeverityNotice])},
+			{"title": "Files", "value": fmt.Sprint(r.TotalFiles)},
+		}},
+	}
+	for _, line := range r.findingLines() {
+		body = append(body, map[string]any{"type": "TextBlock", "text": line, "wrap": true, "spacing": "Small"})
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}
+
+func prefixed(prefix string, lines []string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = prefix + line
+	}
+	return result
+}
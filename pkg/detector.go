@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Bytes read from the start of a file and handed to every detector
+const HeaderSize = 512
+
+// Recognizes one kind of file from its contents
+type Detector interface {
+	// Unique name of the detector, e.g. "jks"
+	Name() string
+	// Which files the detector wants to see and in what order it is asked
+	Hints() Hints
+	// The type of the file, or nil when it is not of the detector's kind.
+	// header holds up to HeaderSize bytes from the start of the file; r reads
+	// the whole file for detectors that need to look further.
+	Detect(header []byte, r io.ReaderAt) (*FileType, error)
+}
+
+// What a registry uses to pick and order the detectors it asks
+type Hints struct {
+	// Prefixes one of which a file must start with for the detector to be
+	// asked; none asks the detector about every file
+	Magic [][]byte
+	// Detectors with a higher priority are asked first, in the order they
+	// were registered when their priorities are equal
+	Priority int
+}
+
+// Wraps a function as a Detector
+func NewDetector(name string, hints Hints, detect func(header []byte, r io.ReaderAt) (*FileType, error)) Detector {
+	return &funcDetector{name: name, hints: hints, detect: detect}
+}
+
+type funcDetector struct {
+	name   string
+	hints  Hints
+	detect func(header []byte, r io.ReaderAt) (*FileType, error)
+}
+
+func (d *funcDetector) Name() string { return d.name }
+func (d *funcDetector) Hints() Hints { return d.hints }
+func (d *funcDetector) Detect(header []byte, r io.ReaderAt) (*FileType, error) {
+	return d.detect(header, r)
+}
+
+// The type reported when no detector recognizes a file
+var UnknownFileType = FileType{
+	Extension:   "",
+	MimeType:    "application/octet-stream",
+	Description: "Unknown File Type",
+}
+
+// An ordered set of detectors, safe for use by several goroutines
+type Registry struct {
+	mu        sync.RWMutex
+	detectors []Detector
+}
+
+// A registry holding the detectors, in priority order
+func NewRegistry(detectors ...Detector) (*Registry, error) {
+	r := &Registry{}
+	for _, d := range detectors {
+		if err := r.Register(d); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Adds a detector; its name must not be taken
+func (r *Registry) Register(d Detector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.detectors {
+		if existing.Name() == d.Name() {
+			return fmt.Errorf("a detector named %q is already registered", d.Name())
+		}
+	}
+	r.detectors = append(r.detectors, d)
+	sort.SliceStable(r.detectors, func(i, j int) bool {
+		return r.detectors[i].Hints().Priority > r.detectors[j].Hints().Priority
+	})
+	return nil
+}
+
+// The detectors, in the order they are asked
+func (r *Registry) Detectors() []Detector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Detector(nil), r.detectors...)
+}
+
+// Asks each detector whose magic matches the header in turn and returns the
+// first type found, or UnknownFileType
+func (r *Registry) Detect(header []byte, reader io.ReaderAt) (*FileType, error) {
+	for _, d := range r.Detectors() {
+		if !matchesMagic(header, d.Hints().Magic) {
+			continue
+		}
+		filetype, err := d.Detect(header, reader)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: %w", d.Name(), err)
+		}
+		if filetype != nil {
+			return filetype, nil
+		}
+	}
+	unknown := UnknownFileType
+	return &unknown, nil
+}
+
+func matchesMagic(header []byte, magic [][]byte) bool {
+	if len(magic) == 0 {
+		return true
+	}
+	for _, prefix := range magic {
+		if bytes.HasPrefix(header, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultRegistry = mustRegistry(builtinDetectors()...)
+
+func mustRegistry(detectors ...Detector) *Registry {
+	r, err := NewRegistry(detectors...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Adds a detector to the ones GetFileType asks, e.g. for an in-house format
+func Register(d Detector) error {
+	return defaultRegistry.Register(d)
+}
+
+// The detectors GetFileType asks, in order
+func Detectors() []Detector {
+	return defaultRegistry.Detectors()
+}
@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Priorities of the built-in detectors: exact magic numbers first, then
+// structures that start with an ASN.1 SEQUENCE, executables and finally text
+const (
+	PriorityMagic      = 100
+	PriorityPEM        = 90
+	PriorityPKCS12     = 80
+	PriorityDER        = 70
+	PriorityExecutable = 60
+	PriorityText       = 0
+)
+
+func builtinDetectors() []Detector {
+	return []Detector{
+		// JKS files start with the magic number 0xFEEDFEED
+		fixedType("jks", []byte{0xFE, 0xED, 0xFE, 0xED}, PriorityMagic, FileType{
+			Extension:   ".jks",
+			MimeType:    "application/x-java-keystore",
+			Description: "Java KeyStore (JKS)",
+		}),
+		fixedType("jceks", []byte{0xCE, 0xCE, 0xCE, 0xCE}, PriorityMagic, FileType{
+			Extension:   ".jceks",
+			MimeType:    "application/x-java-keystore",
+			Description: "Java Cryptography Extension KeyStore (JCEKS)",
+		}),
+		NewDetector("pem", Hints{Magic: [][]byte{[]byte("-----BEGIN ")}, Priority: PriorityPEM}, detectPEM),
+		NewDetector("pkcs12", Hints{Magic: [][]byte{{0x30}}, Priority: PriorityPKCS12}, detectPKCS12),
+		NewDetector("der", Hints{Magic: [][]byte{{0x30}}, Priority: PriorityDER}, detectDER),
+		fixedType("pe", []byte{0x4D, 0x5A}, PriorityExecutable, FileType{
+			Extension:   ".exe",
+			MimeType:    "application/x-msdownload",
+			Description: "Windows Executable",
+		}),
+		fixedType("elf", []byte{0x7F, 0x45, 0x4C, 0x46}, PriorityExecutable, FileType{
+			Extension:   "", // Could be any executable on Linux
+			MimeType:    "application/x-executable",
+			Description: "Linux Executable",
+		}),
+		NewDetector("text", Hints{Priority: PriorityText}, detectText),
+	}
+}
+
+// A detector for files recognized by their magic number alone
+func fixedType(name string, magic []byte, priority int, filetype FileType) Detector {
+	return NewDetector(name, Hints{Magic: [][]byte{magic}, Priority: priority}, func([]byte, io.ReaderAt) (*FileType, error) {
+		result := filetype
+		return &result, nil
+	})
+}
+
+func detectPEM(header []byte, _ io.ReaderAt) (*FileType, error) {
+	// The type is in the first header line
+	headerStr := string(header[:min(len(header), 100)])
+	pemType := "Certificate"
+
+	if strings.Contains(headerStr, "CERTIFICATE REQUEST") || strings.Contains(headerStr, "CSR") {
+		pemType = "Certificate Signing Request"
+	} else if strings.Contains(headerStr, "CERTIFICATE") {
+		pemType = "Certificate"
+	} else if strings.Contains(headerStr, "PRIVATE KEY") {
+		pemType = "Private Key"
+	} else if strings.Contains(headerStr, "PUBLIC KEY") {
+		pemType = "Public Key"
+	}
+
+	return &FileType{
+		Extension:   ".pem",
+		MimeType:    "application/x-pem-file",
+		Description: fmt.Sprintf("PEM Encoded %s", pemType),
+	}, nil
+}
+
+var (
+	// INTEGER 3, the version of every PFX
+	pfxVersion = []byte{0x02, 0x01, 0x03}
+	// 1.2.840.113549.1.7, the PKCS#7 content types a PFX wraps its contents in
+	oidPKCS7 = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07}
+	// 1.2.840.113549.1.12, the PKCS#12 arc
+	oidPKCS12 = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x0C}
+)
+
+// PKCS#12 / PFX files: a SEQUENCE holding version 3 and PKCS#7 content
+func detectPKCS12(header []byte, _ io.ReaderAt) (*FileType, error) {
+	if len(header) < 4 || header[1] < 0x80 {
+		return nil, nil
+	}
+	start := header[:min(len(header), 32)]
+	version := bytes.Index(start, pfxVersion)
+	if (version < 0 || !bytes.Contains(start[version:], oidPKCS7)) && !bytes.Contains(header[:min(len(header), 10)], oidPKCS12) {
+		return nil, nil
+	}
+	return &FileType{
+		Extension:   ".p12",
+		MimeType:    "application/x-pkcs12",
+		Description: "PKCS#12 / PFX Certificate Store",
+	}, nil
+}
+
+// DER files: an ASN.1 SEQUENCE, told apart by common OIDs and structures
+func detectDER(header []byte, _ io.ReaderAt) (*FileType, error) {
+	if len(header) < 2 {
+		return nil, nil
+	}
+	derType := "Unknown"
+
+	// Certificate typically starts with 0x30 0x82 (sequence with 2-byte length)
+	// followed by version, serial number, and signature algorithm
+	if len(header) > 15 &&
+		header[1] >= 0x80 &&
+		bytes.Contains(header[:15], []byte{0x06, 0x03, 0x55, 0x04}) { // Contains an OID from X.500 directory
+		derType = "Certificate"
+	}
+
+	// RSA private key typically starts with 0x30 0x82 followed by a version (usually 0x02 0x01 0x00)
+	if len(header) > 10 &&
+		header[1] >= 0x80 &&
+		bytes.Contains(header[:10], []byte{0x02, 0x01, 0x00}) {
+		derType = "Private Key"
+	}
+
+	// Public key typically has subjectPublicKeyInfo sequence
+	if len(header) > 15 &&
+		bytes.Contains(header[:15], []byte{0x06, 0x09, 0x2A, 0x86, 0x48}) { // Contains RSA OID
+		derType = "Public Key"
+	}
+
+	return &FileType{
+		Extension:   ".der",
+		MimeType:    "application/x-x509-ca-cert",
+		Description: fmt.Sprintf("DER Encoded %s", derType),
+	}, nil
+}
+
+// Text files are hard to detect reliably: most of the header must be printable
+func detectText(header []byte, _ io.ReaderAt) (*FileType, error) {
+	if !isTextFile(header) {
+		return nil, nil
+	}
+	return &FileType{
+		Extension:   ".txt",
+		MimeType:    "text/plain",
+		Description: "Text File",
+	}, nil
+}
+
+func isTextFile(buffer []byte) bool {
+	// Check if buffer contains mostly printable ASCII characters
+	printableCount := 0
+	for _, b := range buffer {
+		if (b >= 32 && b <= 126) || b == 9 || b == 10 || b == 13 {
+			printableCount++
+		}
+	}
+
+	// If more than 90% of characters are printable, assume it's text
+	return printableCount > len(buffer)*9/10
+}
@@ -0,0 +1,57 @@
+(function () {
+  "use strict";
+
+  var table = document.getElementById("certificates");
+  var body = table.tBodies[0];
+  var filter = document.getElementById("filter");
+  var compliance = document.getElementById("compliance");
+
+  function cellValue(row, index, type) {
+    var cell = row.cells[index];
+    var value = cell.getAttribute("data-sort") || cell.textContent.trim().toLowerCase();
+    return type === "number" ? parseFloat(value) : value;
+  }
+
+  // Sort on header click, toggling the direction on repeated clicks
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (header, index) {
+    header.addEventListener("click", function () {
+      var ascending = header.getAttribute("aria-sort") !== "ascending";
+      var type = header.getAttribute("data-type");
+      Array.prototype.forEach.call(table.tHead.rows[0].cells, function (other) {
+        other.removeAttribute("aria-sort");
+      });
+      header.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = cellValue(a, index, type);
+        var y = cellValue(b, index, type);
+        var order = x < y ? -1 : x > y ? 1 : 0;
+        return ascending ? order : -order;
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+
+  function applyFilter() {
+    var text = filter.value.trim().toLowerCase();
+    var status = compliance.value;
+    Array.prototype.forEach.call(body.rows, function (row) {
+      var matches = (!text || row.textContent.toLowerCase().indexOf(text) >= 0) &&
+        (!status || row.getAttribute("data-compliance") === status);
+      row.hidden = !matches;
+    });
+  }
+  filter.addEventListener("input", applyFilter);
+  compliance.addEventListener("change", applyFilter);
+
+  // Open the details panel a table link points at
+  function openTarget() {
+    var target = location.hash && document.getElementById(location.hash.slice(1));
+    if (target && target.tagName === "DETAILS") {
+      target.open = true;
+    }
+  }
+  window.addEventListener("hashchange", openTarget);
+  openTarget();
+})();
@@ -0,0 +1,406 @@
+package report
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+const cycloneDXVersion = "1.6"
+
+// The subset of the CycloneDX 1.6 object model a CBOM needs
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []*cdxComponent `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     cdxTools `json:"tools"`
+}
+
+type cdxTools struct {
+	Components []cdxTool `json:"components"`
+}
+
+type cdxTool struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	Type             string               `json:"type"`
+	BOMRef           string               `json:"bom-ref"`
+	Name             string               `json:"name"`
+	CryptoProperties *cdxCryptoProperties `json:"cryptoProperties"`
+	Properties       []cdxProperty        `json:"properties,omitempty"`
+	Evidence         *cdxEvidence         `json:"evidence,omitempty"`
+}
+
+type cdxCryptoProperties struct {
+	AssetType                       string                   `json:"assetType"`
+	AlgorithmProperties             *cdxAlgorithmProperties  `json:"algorithmProperties,omitempty"`
+	CertificateProperties           *cdxCertificateProps     `json:"certificateProperties,omitempty"`
+	RelatedCryptoMaterialProperties *cdxRelatedMaterialProps `json:"relatedCryptoMaterialProperties,omitempty"`
+}
+
+type cdxAlgorithmProperties struct {
+	Primitive                string   `json:"primitive"`
+	ParameterSetIdentifier   string   `json:"parameterSetIdentifier,omitempty"`
+	Curve                    string   `json:"curve,omitempty"`
+	CryptoFunctions          []string `json:"cryptoFunctions,omitempty"`
+	ClassicalSecurityLevel   int      `json:"classicalSecurityLevel,omitempty"`
+	NISTQuantumSecurityLevel int      `json:"nistQuantumSecurityLevel"`
+}
+
+type cdxCertificateProps struct {
+	SubjectName           string `json:"subjectName"`
+	IssuerName            string `json:"issuerName"`
+	NotValidBefore        string `json:"notValidBefore"`
+	NotValidAfter         string `json:"notValidAfter"`
+	SignatureAlgorithmRef string `json:"signatureAlgorithmRef"`
+	SubjectPublicKeyRef   string `json:"subjectPublicKeyRef,omitempty"`
+	CertificateFormat     string `json:"certificateFormat"`
+	CertificateExtension  string `json:"certificateExtension,omitempty"`
+}
+
+type cdxRelatedMaterialProps struct {
+	Type         string `json:"type"`
+	ID           string `json:"id,omitempty"`
+	AlgorithmRef string `json:"algorithmRef,omitempty"`
+	Size         int    `json:"size,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxEvidence struct {
+	Occurrences []cdxOccurrence `json:"occurrences"`
+}
+
+type cdxOccurrence struct {
+	Location string `json:"location"`
+	Line     int    `json:"line,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+func init() {
+	Register(&Format{Name: "cyclonedx", Extension: ".cdx.json", Write: writeCycloneDX})
+}
+
+// Builds a cryptographic bill of materials from the search result
+type cbomBuilder struct {
+	components map[string]*cdxComponent
+	order      []string
+	depends    map[string]map[string]bool
+	// Public key references and the certificates holding each key, by the
+	// key's fingerprint, to link private keys to them
+	publicKeys   map[string]string
+	certificates map[string][]string
+}
+
+// Writes the inventory as a CycloneDX 1.6 CBOM. Certificates, their public
+// keys and their signature and key algorithms become cryptographic-asset
+// components; private keys found on disk become related crypto material.
+// Certificates depend on their key, signature algorithm and, when it was
+// found in the same scan, their issuer. Private keys depend on the public key
+// and certificates of theirs found in the scan.
+func writeCycloneDX(w io.Writer, result *config.SearchResult) error {
+	serial, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	b := &cbomBuilder{
+		components:   make(map[string]*cdxComponent),
+		depends:      make(map[string]map[string]bool),
+		publicKeys:   make(map[string]string),
+		certificates: make(map[string][]string),
+	}
+
+	// Issuers are matched by subject among the certificates found
+	bySubject := make(map[string][]string)
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for _, cert := range file.Certificates {
+				bySubject[cert.Subject] = append(bySubject[cert.Subject], certificateRef(&cert))
+			}
+		}
+	}
+
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for _, cert := range file.Certificates {
+				b.addCertificate(&cert, file.Path, extResult.Type, bySubject[cert.Issuer])
+			}
+		}
+	}
+	// Once every certificate is known, as a key's certificate may be in a later file
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			if file.PrivateKey {
+				b.addPrivateKey(&file)
+			}
+		}
+	}
+
+	timestamp := result.SearchTime
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  cycloneDXVersion,
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: timestamp.UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxTool{{Type: "application", Name: "findcert"}}},
+		},
+		Components:   make([]*cdxComponent, 0, len(b.order)),
+		Dependencies: []cdxDependency{},
+	}
+	for _, ref := range b.order {
+		bom.Components = append(bom.Components, b.components[ref])
+		if deps := b.depends[ref]; len(deps) > 0 {
+			dependency := cdxDependency{Ref: ref}
+			for dep := range deps {
+				dependency.DependsOn = append(dependency.DependsOn, dep)
+			}
+			sort.Strings(dependency.DependsOn)
+			bom.Dependencies = append(bom.Dependencies, dependency)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(bom)
+}
+
+// Adds the component unless one with the same reference exists, and returns the one kept
+func (b *cbomBuilder) add(component *cdxComponent) *cdxComponent {
+	if existing, ok := b.components[component.BOMRef]; ok {
+		return existing
+	}
+	b.components[component.BOMRef] = component
+	b.order = append(b.order, component.BOMRef)
+	return component
+}
+
+func (b *cbomBuilder) dependOn(ref, dependency string) {
+	if ref == dependency {
+		// Self-signed certificates
+		return
+	}
+	if b.depends[ref] == nil {
+		b.depends[ref] = make(map[string]bool)
+	}
+	b.depends[ref][dependency] = true
+}
+
+func (b *cbomBuilder) addCertificate(cert *config.CertificateInfo, path, extension string, issuers []string) {
+	sigRef := b.addSignatureAlgorithm(cert.SignatureAlgorithm)
+	keyRef := b.addPublicKey(cert)
+
+	ref := certificateRef(cert)
+	component := b.add(&cdxComponent{
+		Type:   "cryptographic-asset",
+		BOMRef: ref,
+		Name:   cert.Subject,
+		CryptoProperties: &cdxCryptoProperties{
+			AssetType: "certificate",
+			CertificateProperties: &cdxCertificateProps{
+				SubjectName:           cert.Subject,
+				IssuerName:            cert.Issuer,
+				NotValidBefore:        cert.NotBefore.UTC().Format(time.RFC3339),
+				NotValidAfter:         cert.NotAfter.UTC().Format(time.RFC3339),
+				SignatureAlgorithmRef: sigRef,
+				SubjectPublicKeyRef:   keyRef,
+				CertificateFormat:     "X.509",
+				CertificateExtension:  strings.TrimPrefix(extension, "."),
+			},
+		},
+		Properties: []cdxProperty{
+			{Name: "findcert:serial_number", Value: cert.SerialNumber},
+			{Name: "findcert:fingerprint_sha256", Value: cert.Fingerprint},
+			{Name: "findcert:compliance", Value: cert.ComplianceStatus()},
+		},
+		Evidence: &cdxEvidence{},
+	})
+
+	occurrence := cdxOccurrence{Location: path}
+	if cert.Lines != nil {
+		occurrence.Line = cert.Lines.Start
+	}
+	component.Evidence.Occurrences = append(component.Evidence.Occurrences, occurrence)
+
+	b.dependOn(ref, sigRef)
+	if keyRef != "" {
+		b.dependOn(ref, keyRef)
+		b.certificates[cert.PublicKeyFingerprint] = append(b.certificates[cert.PublicKeyFingerprint], ref)
+	}
+	for _, issuer := range issuers {
+		b.dependOn(ref, issuer)
+	}
+}
+
+// Signature and key algorithms get references of their own, as Ed25519 names both
+func (b *cbomBuilder) addSignatureAlgorithm(name string) string {
+	ref := "crypto/algorithm/signature/" + strings.ToLower(name)
+	b.add(&cdxComponent{
+		Type:   "cryptographic-asset",
+		BOMRef: ref,
+		Name:   name,
+		CryptoProperties: &cdxCryptoProperties{
+			AssetType: "algorithm",
+			AlgorithmProperties: &cdxAlgorithmProperties{
+				Primitive:       "signature",
+				CryptoFunctions: []string{"sign", "verify"},
+			},
+		},
+	})
+	return ref
+}
+
+// Adds the certificate's public key and its algorithm, and returns the key's reference
+func (b *cbomBuilder) addPublicKey(cert *config.CertificateInfo) string {
+	if cert.PublicKeyFingerprint == "" {
+		return ""
+	}
+
+	name, primitive := cert.PublicKeyAlgorithm, "signature"
+	switch cert.PublicKeyAlgorithm {
+	case "RSA":
+		name, primitive = fmt.Sprintf("RSA-%d", cert.PublicKeySize), "pke"
+	case "ECDSA":
+		name = "ECDSA-" + cert.PublicKeyCurve
+	}
+	algorithm := &cdxAlgorithmProperties{
+		Primitive:              primitive,
+		ClassicalSecurityLevel: classicalSecurityLevel(cert),
+	}
+	if cert.PublicKeySize > 0 {
+		algorithm.ParameterSetIdentifier = strconv.Itoa(cert.PublicKeySize)
+	}
+	if cert.PublicKeyAlgorithm == "ECDSA" {
+		algorithm.Curve = cert.PublicKeyCurve
+	}
+	algorithmRef := "crypto/algorithm/key/" + strings.ToLower(name)
+	b.add(&cdxComponent{
+		Type:             "cryptographic-asset",
+		BOMRef:           algorithmRef,
+		Name:             name,
+		CryptoProperties: &cdxCryptoProperties{AssetType: "algorithm", AlgorithmProperties: algorithm},
+	})
+
+	ref := "crypto/key/" + cert.PublicKeyFingerprint
+	b.add(&cdxComponent{
+		Type:   "cryptographic-asset",
+		BOMRef: ref,
+		Name:   name + " public key",
+		CryptoProperties: &cdxCryptoProperties{
+			AssetType: "related-crypto-material",
+			RelatedCryptoMaterialProperties: &cdxRelatedMaterialProps{
+				Type:         "public-key",
+				ID:           cert.PublicKeyFingerprint,
+				AlgorithmRef: algorithmRef,
+				Size:         cert.PublicKeySize,
+			},
+		},
+	})
+	b.dependOn(ref, algorithmRef)
+	b.publicKeys[cert.PublicKeyFingerprint] = ref
+	return ref
+}
+
+func (b *cbomBuilder) addPrivateKey(file *config.FileInfo) {
+	ref := "crypto/private-key/" + file.Path
+	component := b.add(&cdxComponent{
+		Type:   "cryptographic-asset",
+		BOMRef: ref,
+		Name:   "private key in " + file.Path,
+		CryptoProperties: &cdxCryptoProperties{
+			AssetType:                       "related-crypto-material",
+			RelatedCryptoMaterialProperties: &cdxRelatedMaterialProps{Type: "private-key"},
+		},
+		Evidence: &cdxEvidence{},
+	})
+	if len(file.PrivateKeyLines) == 0 {
+		component.Evidence.Occurrences = append(component.Evidence.Occurrences, cdxOccurrence{Location: file.Path})
+	}
+	for _, lines := range file.PrivateKeyLines {
+		component.Evidence.Occurrences = append(component.Evidence.Occurrences, cdxOccurrence{Location: file.Path, Line: lines.Start})
+	}
+
+	props := component.CryptoProperties.RelatedCryptoMaterialProperties
+	for _, fingerprint := range file.PrivateKeyFingerprints {
+		publicRef, ok := b.publicKeys[fingerprint]
+		if !ok {
+			continue
+		}
+		if props.AlgorithmRef == "" {
+			// A key shares the algorithm and size of its public half
+			public := b.components[publicRef].CryptoProperties.RelatedCryptoMaterialProperties
+			props.AlgorithmRef, props.Size = public.AlgorithmRef, public.Size
+		}
+		b.dependOn(ref, publicRef)
+		for _, certificate := range b.certificates[fingerprint] {
+			b.dependOn(ref, certificate)
+		}
+	}
+}
+
+func certificateRef(cert *config.CertificateInfo) string {
+	return "crypto/certificate/" + cert.Fingerprint
+}
+
+// The classical security strength in bits of the key, following NIST SP 800-57
+func classicalSecurityLevel(cert *config.CertificateInfo) int {
+	switch cert.PublicKeyAlgorithm {
+	case "RSA", "DSA":
+		switch {
+		case cert.PublicKeySize >= 15360:
+			return 256
+		case cert.PublicKeySize >= 7680:
+			return 192
+		case cert.PublicKeySize >= 3072:
+			return 128
+		case cert.PublicKeySize >= 2048:
+			return 112
+		default:
+			return 80
+		}
+	case "ECDSA", "Ed25519":
+		return cert.PublicKeySize / 2
+	}
+	return 0
+}
+
+// A random (version 4) UUID
+func newUUID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
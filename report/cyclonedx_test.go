@@ -0,0 +1,190 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/cmd"
+)
+
+func TestWriteCycloneDX(t *testing.T) {
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CBOM Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(0x0102030405060708),
+		Subject:      pkix.Name{CommonName: "cbom.example.com"},
+		DNSNames:     []string{"cbom.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create leaf: %v", err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(leafKey)
+
+	for name, block := range map[string]*pem.Block{
+		"ca.pem":   {Type: "CERTIFICATE", Bytes: caDER},
+		"leaf.crt": {Type: "CERTIFICATE", Bytes: leafDER},
+		"leaf.pem": {Type: "PRIVATE KEY", Bytes: keyDER},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeCycloneDX(&out, scanDir(t, dir)); err != nil {
+		t.Fatalf("writeCycloneDX failed: %v", err)
+	}
+
+	var bom cdxBOM
+	if err := json.Unmarshal(out.Bytes(), &bom); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.6" {
+		t.Errorf("Unexpected BOM header %s %s", bom.BOMFormat, bom.SpecVersion)
+	}
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(bom.SerialNumber) {
+		t.Errorf("Invalid serial number %s", bom.SerialNumber)
+	}
+
+	components := make(map[string]*cdxComponent)
+	for _, component := range bom.Components {
+		components[component.BOMRef] = component
+	}
+	leafRef := "crypto/certificate/" + fingerprint(leafDER)
+	caRef := "crypto/certificate/" + fingerprint(caDER)
+	if components[leafRef] == nil || components[caRef] == nil {
+		t.Fatalf("Expected both certificates as components, got %v", keys(components))
+	}
+	if components[leafRef].CryptoProperties.AssetType != "certificate" {
+		t.Errorf("Unexpected leaf asset type %s", components[leafRef].CryptoProperties.AssetType)
+	}
+
+	curve := components["crypto/algorithm/key/ecdsa-p-384"]
+	if curve == nil || curve.CryptoProperties.AlgorithmProperties.ClassicalSecurityLevel != 192 {
+		t.Errorf("Expected an ECDSA P-384 algorithm with 192 bit security, got %+v", curve)
+	}
+
+	var privateKeys []*cdxComponent
+	for _, component := range bom.Components {
+		if props := component.CryptoProperties.RelatedCryptoMaterialProperties; props != nil && props.Type == "private-key" {
+			privateKeys = append(privateKeys, component)
+		}
+	}
+	if len(privateKeys) != 1 {
+		t.Fatalf("Expected 1 private key component, got %d", len(privateKeys))
+	}
+	if ref := privateKeys[0].CryptoProperties.RelatedCryptoMaterialProperties.AlgorithmRef; ref != "crypto/algorithm/key/ecdsa-p-256" {
+		t.Errorf("Expected the private key to refer to its algorithm, got %q", ref)
+	}
+
+	var leafDeps, keyDeps []string
+	for _, dependency := range bom.Dependencies {
+		switch dependency.Ref {
+		case leafRef:
+			leafDeps = dependency.DependsOn
+		case privateKeys[0].BOMRef:
+			keyDeps = dependency.DependsOn
+		}
+		for _, ref := range dependency.DependsOn {
+			if components[ref] == nil {
+				t.Errorf("Dependency %s of %s is not a component", ref, dependency.Ref)
+			}
+		}
+	}
+	expected := map[string]bool{caRef: true, "crypto/algorithm/signature/ecdsa-sha384": true}
+	for _, dep := range leafDeps {
+		delete(expected, dep)
+	}
+	if len(expected) != 0 || len(leafDeps) != 3 {
+		t.Errorf("Expected the leaf to depend on its key, issuer and signature algorithm, got %v", leafDeps)
+	}
+	leafKeyRef := components[leafRef].CryptoProperties.CertificateProperties.SubjectPublicKeyRef
+	if len(keyDeps) != 2 || keyDeps[0] != leafRef || keyDeps[1] != leafKeyRef {
+		t.Errorf("Expected the private key to depend on the leaf and its public key, got %v", keyDeps)
+	}
+}
+
+func TestWriteCycloneDX_Ed25519Algorithms(t *testing.T) {
+	dir := t.TempDir()
+
+	public, key, _ := ed25519.GenerateKey(rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x0102030405060708),
+		Subject:      pkix.Name{CommonName: "ed25519.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, public, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ed25519.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := writeCycloneDX(&out, scanDir(t, dir)); err != nil {
+		t.Fatalf("writeCycloneDX failed: %v", err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(out.Bytes(), &bom); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	components := make(map[string]*cdxComponent)
+	for _, component := range bom.Components {
+		components[component.BOMRef] = component
+	}
+
+	signature, keyAlgorithm := components["crypto/algorithm/signature/ed25519"], components["crypto/algorithm/key/ed25519"]
+	if signature == nil || keyAlgorithm == nil {
+		t.Fatalf("Expected separate signature and key algorithms, got %v", keys(components))
+	}
+	if keyAlgorithm.CryptoProperties.AlgorithmProperties.ClassicalSecurityLevel != 128 {
+		t.Errorf("Expected the key algorithm to keep its security level, got %+v", keyAlgorithm.CryptoProperties.AlgorithmProperties)
+	}
+}
+
+func fingerprint(der []byte) string {
+	cert, _ := x509.ParseCertificate(der)
+	return cmd.Fingerprint(cert)
+}
+
+func keys(m map[string]*cdxComponent) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
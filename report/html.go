@@ -0,0 +1,183 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+//go:embed assets/report.html.tmpl assets/report.css assets/report.js
+var assets embed.FS
+
+var htmlTemplate = template.Must(template.New("report.html.tmpl").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+}).ParseFS(assets, "assets/report.html.tmpl"))
+
+// Months shown on the expiry timeline, starting with the current one
+const timelineMonths = 12
+
+// Everything the HTML template renders
+type htmlReport struct {
+	Generated    time.Time
+	SearchPath   string
+	TotalFiles   int
+	Counts       map[string]int
+	Certificates []htmlCertificate
+	Policies     []htmlPolicy
+	Timeline     []timelineBucket
+	Style        template.CSS
+	Script       template.JS
+}
+
+type htmlCertificate struct {
+	ID         int
+	Path       string
+	Type       string
+	Info       config.CertificateInfo
+	Compliance string
+	DaysLeft   int
+}
+
+// How one rule fared across the scan
+type htmlPolicy struct {
+	Rule     cmd.Rule
+	Findings int
+}
+
+// Certificates expiring in one month of the timeline
+type timelineBucket struct {
+	Label string
+	Count int
+	// Bar height in percent of the tallest bar
+	Height int
+	// Whether certificates in the bucket have already expired
+	Expired bool
+}
+
+func init() {
+	Register(&Format{Name: "html", Extension: ".html", Write: writeHTML})
+}
+
+// Writes a single self-contained HTML page: the styles and script are inlined
+// and the timeline is drawn without external libraries, so it works offline.
+func writeHTML(w io.Writer, result *config.SearchResult) error {
+	style, err := assets.ReadFile("assets/report.css")
+	if err != nil {
+		return err
+	}
+	script, err := assets.ReadFile("assets/report.js")
+	if err != nil {
+		return err
+	}
+
+	now := result.SearchTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	data := htmlReport{
+		Generated:  now,
+		SearchPath: result.SearchPath,
+		TotalFiles: result.TotalFiles,
+		Counts:     map[string]int{"compliant": 0, "non-compliant": 0, "revoked": 0},
+		Style:      template.CSS(style),
+		Script:     template.JS(script),
+	}
+
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for _, cert := range file.Certificates {
+				compliance := cert.ComplianceStatus()
+				data.Counts[compliance]++
+				data.Certificates = append(data.Certificates, htmlCertificate{
+					ID:         len(data.Certificates) + 1,
+					Path:       file.Path,
+					Type:       extResult.Type,
+					Info:       cert,
+					Compliance: compliance,
+					DaysLeft:   daysLeft(cert.NotAfter, now),
+				})
+			}
+		}
+	}
+	sort.SliceStable(data.Certificates, func(i, j int) bool {
+		return data.Certificates[i].Info.NotAfter.Before(data.Certificates[j].Info.NotAfter)
+	})
+
+	counts := make(map[string]int)
+	for _, finding := range cmd.CollectFindings(result) {
+		counts[finding.RuleID]++
+	}
+	for _, rule := range cmd.Rules() {
+		data.Policies = append(data.Policies, htmlPolicy{Rule: rule, Findings: counts[rule.ID]})
+	}
+
+	data.Timeline = expiryTimeline(data.Certificates, now)
+	return htmlTemplate.Execute(w, data)
+}
+
+// Buckets certificates by expiry: already expired, each of the coming months,
+// and everything after that
+func expiryTimeline(certs []htmlCertificate, now time.Time) []timelineBucket {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	buckets := make([]timelineBucket, timelineMonths+2)
+	buckets[0] = timelineBucket{Label: "Expired", Expired: true}
+	for i := 0; i < timelineMonths; i++ {
+		buckets[i+1].Label = start.AddDate(0, i, 0).Format("Jan 2006")
+	}
+	buckets[timelineMonths+1].Label = "Later"
+
+	for _, cert := range certs {
+		notAfter := cert.Info.NotAfter
+		switch {
+		case notAfter.Before(now):
+			buckets[0].Count++
+		default:
+			months := (notAfter.Year()-start.Year())*12 + int(notAfter.Month()) - int(start.Month())
+			if months >= timelineMonths {
+				buckets[timelineMonths+1].Count++
+			} else {
+				buckets[months+1].Count++
+			}
+		}
+	}
+
+	max := 0
+	for _, bucket := range buckets {
+		if bucket.Count > max {
+			max = bucket.Count
+		}
+	}
+	for i := range buckets {
+		if max > 0 {
+			buckets[i].Height = buckets[i].Count * 100 / max
+		}
+	}
+	return buckets
+}
+
+// Whole days until notAfter, rounded down so that a certificate that expired
+// hours ago counts as expired rather than expiring today
+func daysLeft(notAfter, now time.Time) int {
+	return int(math.Floor(notAfter.Sub(now).Hours() / 24))
+}
+
+// Formats the days until expiry for the table
+func (c htmlCertificate) Expiry() string {
+	switch {
+	case c.DaysLeft < 0:
+		return fmt.Sprintf("expired %d days ago", -c.DaysLeft)
+	case c.DaysLeft == 0:
+		return "expires today"
+	default:
+		return fmt.Sprintf("%d days", c.DaysLeft)
+	}
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func TestWriteHTML(t *testing.T) {
+	result := testSearchResult()
+	result.SearchTime = time.Date(2027, 1, 15, 0, 0, 0, 0, time.UTC)
+	result.Results[0].Files[0].Certificates[1].Subject = "CN=<script>alert(1)</script>"
+
+	var buf bytes.Buffer
+	if err := writeHTML(&buf, result); err != nil {
+		t.Fatalf("writeHTML failed: %v", err)
+	}
+	page := buf.String()
+
+	for _, expected := range []string{
+		"CN=www.example.com",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`id="cert-1"`,
+		"fips-signature-algorithm",
+		"Mar 2027",
+		`<th data-type="number">Expires</th>`,
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the report to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<script>alert(1)") {
+		t.Error("Certificate fields must be escaped")
+	}
+
+	// The page must not load anything from elsewhere
+	if external := regexp.MustCompile(`(?i)(src|href)="(https?:)?//`).FindString(page); external != "" {
+		t.Errorf("Expected a self-contained page, found %s", external)
+	}
+}
+
+func TestExpiryTimeline(t *testing.T) {
+	now := time.Date(2027, 1, 15, 0, 0, 0, 0, time.UTC)
+	certs := []htmlCertificate{
+		{Info: config.CertificateInfo{NotAfter: now.AddDate(0, 0, -1)}},
+		{Info: config.CertificateInfo{NotAfter: now.AddDate(0, 0, 5)}},
+		{Info: config.CertificateInfo{NotAfter: now.AddDate(0, 0, 10)}},
+		{Info: config.CertificateInfo{NotAfter: now.AddDate(0, 2, 0)}},
+		{Info: config.CertificateInfo{NotAfter: now.AddDate(3, 0, 0)}},
+	}
+
+	buckets := expiryTimeline(certs, now)
+	if len(buckets) != timelineMonths+2 {
+		t.Fatalf("Expected %d buckets, got %d", timelineMonths+2, len(buckets))
+	}
+	expected := map[int]int{0: 1, 1: 2, 3: 1, timelineMonths + 1: 1}
+	for i, bucket := range buckets {
+		if bucket.Count != expected[i] {
+			t.Errorf("Bucket %s: expected %d, got %d", bucket.Label, expected[i], bucket.Count)
+		}
+	}
+	if buckets[1].Label != "Jan 2027" || buckets[1].Height != 100 || buckets[0].Height != 50 {
+		t.Errorf("Unexpected current month bucket %+v", buckets[1])
+	}
+}
+
+func TestHTMLCertificate_Expiry(t *testing.T) {
+	now := time.Date(2027, 1, 15, 12, 0, 0, 0, time.UTC)
+	for notAfter, expected := range map[time.Time]string{
+		now.Add(-3 * time.Hour):                "expired 1 days ago",
+		now.Add(3 * time.Hour):                 "expires today",
+		now.Add(-49 * time.Hour):               "expired 3 days ago",
+		now.Add(10*24*time.Hour + time.Minute): "10 days",
+	} {
+		cert := htmlCertificate{DaysLeft: daysLeft(notAfter, now)}
+		if got := cert.Expiry(); got != expected {
+			t.Errorf("%s: expected %q, got %q", notAfter, expected, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+// Record types of the NDJSON format, one JSON object per line
+const (
+	recordFile    = "file"
+	recordFinding = "finding"
+	recordSummary = "summary"
+)
+
+type ndjsonFile struct {
+	Type      string `json:"type"`
+	Extension string `json:"extension"`
+	*config.FileInfo
+}
+
+type ndjsonFinding struct {
+	Type string `json:"type"`
+	config.LocatedFinding
+}
+
+type ndjsonSummary struct {
+	Type          string                `json:"type"`
+	SchemaVersion string                `json:"schema_version"`
+	SearchPath    string                `json:"search_path"`
+	TotalFiles    int                   `json:"total_files"`
+	Certificates  int                   `json:"certificates"`
+	Findings      map[string]int        `json:"findings"`
+	CRLs          []config.CRLInfo      `json:"crls,omitempty"`
+	Hosts         []config.HostCoverage `json:"host_coverage,omitempty"`
+	SearchTime    time.Time             `json:"search_time"`
+	Duration      float64               `json:"duration_seconds,omitempty"`
+	Incomplete    bool                  `json:"incomplete,omitempty"`
+	Cache         *config.CacheStats    `json:"cache,omitempty"`
+}
+
+// Writes each record as a line as soon as it is given
+type ndjsonStream struct {
+	encoder      *json.Encoder
+	files        int
+	certificates int
+	findings     map[string]int
+}
+
+func init() {
+	Register(&Format{Name: "ndjson", Extension: ".ndjson", Write: writeNDJSON, NewStream: newNDJSONStream})
+}
+
+func newNDJSONStream(w io.Writer) Stream {
+	return &ndjsonStream{
+		encoder: json.NewEncoder(w),
+		findings: map[string]int{
+			config.SeverityError:   0,
+			config.SeverityWarning: 0,
+			config.SeverityNotice:  0,
+		},
+	}
+}
+
+func (s *ndjsonStream) File(extension string, file *config.FileInfo) error {
+	s.files++
+	s.certificates += len(file.Certificates)
+	return s.encoder.Encode(ndjsonFile{Type: recordFile, Extension: extension, FileInfo: file})
+}
+
+func (s *ndjsonStream) Finding(finding config.LocatedFinding) error {
+	s.findings[finding.Severity]++
+	return s.encoder.Encode(ndjsonFinding{Type: recordFinding, LocatedFinding: finding})
+}
+
+func (s *ndjsonStream) Finish(result *config.SearchResult) error {
+	return s.encoder.Encode(ndjsonSummary{
+		Type:          recordSummary,
+		SchemaVersion: result.SchemaVersion,
+		SearchPath:    result.SearchPath,
+		TotalFiles:    s.files,
+		Certificates:  s.certificates,
+		Findings:      s.findings,
+		CRLs:          result.CRLs,
+		Hosts:         result.Hosts,
+		SearchTime:    result.SearchTime,
+		Duration:      result.Duration,
+		Incomplete:    result.Incomplete,
+		Cache:         result.Cache,
+	})
+}
+
+// Writes a completed search in the same records a streamed scan produces
+func writeNDJSON(w io.Writer, result *config.SearchResult) error {
+	stream := newNDJSONStream(w)
+	for _, extResult := range result.Results {
+		for i := range extResult.Files {
+			file := &extResult.Files[i]
+			if err := stream.File(extResult.Type, file); err != nil {
+				return err
+			}
+			for _, finding := range cmd.ReportedFileFindings(result, file) {
+				if err := stream.Finding(finding); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, finding := range cmd.ReportedResultFindings(result) {
+		if err := stream.Finding(finding); err != nil {
+			return err
+		}
+	}
+	return stream.Finish(result)
+}
@@ -0,0 +1,58 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"org.gkh/findcert/config"
+)
+
+func TestWriteNDJSON_BaselineAndDisabledRules(t *testing.T) {
+	result := testSearchResult()
+	result.Results[1].Files[0].PrivateKey = true
+	result.CRLs = []config.CRLInfo{
+		{Path: "/etc/ssl/stale.crl", Issuer: "CN=Example CA", Verified: true, Stale: true},
+		{Path: "/etc/ssl/other.crl", Issuer: "CN=Other CA"},
+	}
+	result.Hosts = []config.HostCoverage{{Host: "api.example.com"}, {Host: "www.example.com"}}
+	result.Suppressed = []config.SuppressedFinding{{
+		LocatedFinding: config.LocatedFinding{Finding: config.Finding{RuleID: "crl-unverified"}, Path: "/etc/ssl/other.crl", Subject: "CN=Other CA"},
+		Justification:  "Partner CA",
+	}}
+	result.DisabledRules = []string{"crl-stale", "private-key-file"}
+
+	var buf bytes.Buffer
+	if err := writeNDJSON(&buf, result); err != nil {
+		t.Fatalf("writeNDJSON failed: %v", err)
+	}
+
+	var rules []string
+	var summary ndjsonSummary
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record struct {
+			Type   string `json:"type"`
+			RuleID string `json:"rule_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid record %s: %v", line, err)
+		}
+		switch record.Type {
+		case recordFinding:
+			rules = append(rules, record.RuleID)
+		case recordSummary:
+			json.Unmarshal([]byte(line), &summary)
+		}
+	}
+	sort.Strings(rules)
+	// Neither the accepted CRL finding nor those of the disabled rules
+	expected := "fips-public-key,fips-signature-algorithm,host-not-covered,host-not-covered"
+	if got := strings.Join(rules, ","); got != expected {
+		t.Errorf("Expected findings %s, got %s", expected, got)
+	}
+	if summary.Findings[config.SeverityError] != 4 || summary.Findings[config.SeverityWarning] != 0 {
+		t.Errorf("Expected the summary to count only the findings written, got %v", summary.Findings)
+	}
+}
@@ -0,0 +1,190 @@
+package report
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+func init() {
+	Register(&Format{Name: "prometheus", Extension: ".prom", Write: WritePrometheus})
+}
+
+// One metric family of the Prometheus text exposition format
+type metricFamily struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metricFamily) add(value float64, labels ...string) {
+	s := sample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.labels = append(s.labels, [2]string{labels[i], labels[i+1]})
+	}
+	m.samples = append(m.samples, s)
+}
+
+// Writes the search result as gauges in the Prometheus text format, for the
+// node_exporter textfile collector
+func WritePrometheus(w io.Writer, result *config.SearchResult) error {
+	return writeMetrics(w, prometheusMetrics(result))
+}
+
+func prometheusMetrics(result *config.SearchResult) []*metricFamily {
+	scanTime := &metricFamily{name: "findcert_scan_timestamp_seconds", help: "When the scan finished, as a Unix time."}
+	scanTime.add(unixSeconds(result.SearchTime))
+	duration := &metricFamily{name: "findcert_scan_duration_seconds", help: "How long the scan took."}
+	duration.add(result.Duration)
+	incomplete := &metricFamily{name: "findcert_scan_incomplete", help: "Whether the scan stopped before it had seen every file (1) or not (0)."}
+	incomplete.add(boolValue(result.Incomplete))
+	cacheFiles := &metricFamily{name: "findcert_cache_files", help: "Files the scan took from its cache (hit) or had to read (miss)."}
+	if result.Cache != nil {
+		cacheFiles.add(float64(result.Cache.Hits), "result", "hit")
+		cacheFiles.add(float64(result.Cache.Misses), "result", "miss")
+	}
+	files := &metricFamily{name: "findcert_files", help: "Files found by the scan."}
+	files.add(float64(result.TotalFiles))
+	fileErrors := &metricFamily{name: "findcert_file_errors", help: "Files that could not be read (unreadable), or that held no certificate or private key (unparsable). CRL files are counted by findcert_crl_errors."}
+	unreadable, unparsable := 0, 0
+
+	notAfter := &metricFamily{name: "findcert_cert_not_after_seconds", help: "When the certificate expires, as a Unix time."}
+	compliant := &metricFamily{name: "findcert_cert_compliant", help: "Whether the certificate passes every compliance rule (1) or not (0)."}
+	statuses := map[string]int{"compliant": 0, "non-compliant": 0, "revoked": 0}
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for i := range file.Certificates {
+				cert := &file.Certificates[i]
+				labels := []string{"path", file.Path, "subject", cert.Subject, "serial", cert.SerialNumber}
+				notAfter.add(unixSeconds(cert.NotAfter), labels...)
+				compliant.add(boolValue(cert.Compliant && !cert.Revoked), labels...)
+				statuses[cert.ComplianceStatus()]++
+			}
+			switch {
+			case extResult.Type == ".crl":
+			case file.Error != "":
+				unreadable++
+			case len(file.Certificates) == 0 && !file.PrivateKey:
+				unparsable++
+			}
+		}
+	}
+	fileErrors.add(float64(unreadable), "reason", "unreadable")
+	fileErrors.add(float64(unparsable), "reason", "unparsable")
+	certificates := &metricFamily{name: "findcert_certificates", help: "Certificates found, by compliance status."}
+	for _, status := range sortedKeys(statuses) {
+		certificates.add(float64(statuses[status]), "status", status)
+	}
+
+	findings := cmd.CollectFindings(result)
+	severities := map[string]int{config.SeverityError: 0, config.SeverityWarning: 0, config.SeverityNotice: 0}
+	rules := make(map[string]int)
+	ruleSeverity := make(map[string]string)
+	for _, finding := range findings {
+		severities[finding.Severity]++
+		rules[finding.RuleID]++
+		ruleSeverity[finding.RuleID] = finding.Severity
+	}
+	bySeverity := &metricFamily{name: "findcert_findings", help: "Findings not suppressed by a baseline, by severity."}
+	for _, severity := range sortedKeys(severities) {
+		bySeverity.add(float64(severities[severity]), "severity", severity)
+	}
+	byRule := &metricFamily{name: "findcert_rule_findings", help: "Findings not suppressed by a baseline, by rule."}
+	for _, rule := range sortedKeys(rules) {
+		byRule.add(float64(rules[rule]), "rule_id", rule, "severity", ruleSeverity[rule])
+	}
+	suppressed := &metricFamily{name: "findcert_suppressed_findings", help: "Findings suppressed by a baseline."}
+	suppressed.add(float64(countSuppressed(result)))
+
+	crlNextUpdate := &metricFamily{name: "findcert_crl_next_update_seconds", help: "When the CRL is due to be replaced, as a Unix time."}
+	crlErrors := &metricFamily{name: "findcert_crl_errors", help: "CRL files that could not be parsed."}
+	errors := 0
+	for _, crl := range result.CRLs {
+		if crl.Error != "" {
+			errors++
+			continue
+		}
+		crlNextUpdate.add(unixSeconds(crl.NextUpdate), "path", crl.Path, "issuer", crl.Issuer)
+	}
+	crlErrors.add(float64(errors))
+
+	hosts := &metricFamily{name: "findcert_host_covered", help: "Whether a valid certificate found in the scan covers the expected host (1) or not (0)."}
+	for _, coverage := range result.Hosts {
+		hosts.add(boolValue(len(coverage.Paths) > 0), "host", coverage.Host)
+	}
+
+	return []*metricFamily{
+		scanTime, duration, incomplete, cacheFiles, files, fileErrors, certificates, notAfter, compliant,
+		bySeverity, byRule, suppressed, crlNextUpdate, crlErrors, hosts,
+	}
+}
+
+func countSuppressed(result *config.SearchResult) int {
+	count := len(result.Suppressed)
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			count += len(file.Suppressed)
+		}
+	}
+	return count
+}
+
+// Writes the families as gauges, leaving out families without samples
+func writeMetrics(w io.Writer, families []*metricFamily) error {
+	out := bufio.NewWriter(w)
+	for _, family := range families {
+		if len(family.samples) == 0 {
+			continue
+		}
+		out.WriteString("# HELP " + family.name + " " + family.help + "\n")
+		out.WriteString("# TYPE " + family.name + " gauge\n")
+		for _, s := range family.samples {
+			out.WriteString(family.name)
+			if len(s.labels) > 0 {
+				out.WriteByte('{')
+				for i, label := range s.labels {
+					if i > 0 {
+						out.WriteByte(',')
+					}
+					out.WriteString(label[0] + `="` + labelEscaper.Replace(label[1]) + `"`)
+				}
+				out.WriteByte('}')
+			}
+			out.WriteString(" " + strconv.FormatFloat(s.value, 'f', -1, 64) + "\n")
+		}
+	}
+	return out.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.Unix())
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
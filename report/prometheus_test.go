@@ -0,0 +1,70 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	result := testSearchResult()
+	result.SearchTime = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	result.Duration = 1.5
+	result.Results[0].Files[0].Certificates[0].SerialNumber = "1a2b"
+	result.Results[0].Files[0].Certificates[1].Subject = `CN=quote", O=Example`
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, result); err != nil {
+		t.Fatalf("WritePrometheus failed: %v", err)
+	}
+	output := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE findcert_cert_not_after_seconds gauge\n",
+		`findcert_cert_not_after_seconds{path="/etc/ssl/bundle.pem",subject="CN=www.example.com",serial="1a2b"} 1803902400` + "\n",
+		`findcert_cert_compliant{path="/etc/ssl/bundle.pem",subject="CN=quote\", O=Example",serial=""} 0` + "\n",
+		`findcert_certificates{status="non-compliant"} 1` + "\n",
+		`findcert_certificates{status="revoked"} 0` + "\n",
+		`findcert_findings{severity="error"} 2` + "\n",
+		`findcert_rule_findings{rule_id="fips-public-key",severity="error"} 1` + "\n",
+		"findcert_scan_duration_seconds 1.5\n",
+		"findcert_scan_timestamp_seconds 1790812800\n",
+		"findcert_crl_errors 0\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in\n%s", expected, output)
+		}
+	}
+	// Families without samples are left out
+	if strings.Contains(output, "findcert_host_covered") {
+		t.Error("Expected no host metrics without expected hosts")
+	}
+}
+
+func TestWritePrometheus_FileErrors(t *testing.T) {
+	result := testSearchResult()
+	result.Results[1].Files[0].PrivateKey = true
+	result.Results[0].Files = append(result.Results[0].Files,
+		config.FileInfo{Path: "/etc/ssl/locked.pem", Error: "failed to read /etc/ssl/locked.pem: permission denied"},
+		config.FileInfo{Path: "/etc/ssl/request.pem"},
+	)
+	result.Results = append(result.Results, config.ExtensionResult{Type: ".crl", Files: []config.FileInfo{{Path: "/etc/ssl/broken.crl"}}})
+	result.CRLs = []config.CRLInfo{{Path: "/etc/ssl/broken.crl", Error: "not a CRL"}}
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, result); err != nil {
+		t.Fatalf("WritePrometheus failed: %v", err)
+	}
+	for _, expected := range []string{
+		`findcert_file_errors{reason="unreadable"} 1` + "\n",
+		`findcert_file_errors{reason="unparsable"} 1` + "\n",
+		"findcert_crl_errors 1\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in\n%s", expected, buf.String())
+		}
+	}
+}
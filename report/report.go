@@ -0,0 +1,76 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"org.gkh/findcert/config"
+)
+
+// Writes a complete search result to w
+type WriteFunc func(w io.Writer, result *config.SearchResult) error
+
+// Receives a scan's results while it runs, for formats that write them as
+// they are produced instead of once the scan is complete
+type Stream interface {
+	// A file found and inspected, before any revocation is known
+	File(extension string, file *config.FileInfo) error
+	// A finding as soon as it is known
+	Finding(finding config.LocatedFinding) error
+	// The end of the scan. The result carries everything but the files.
+	Finish(result *config.SearchResult) error
+}
+
+// An output format and the file extension its reports use
+type Format struct {
+	Name      string
+	Extension string
+	Write     WriteFunc
+	// Set for formats that can write results as a scan produces them
+	NewStream func(w io.Writer) Stream
+}
+
+var formats = map[string]*Format{}
+
+// Makes a format available by name, replacing any format with the same name
+func Register(format *Format) {
+	formats[format.Name] = format
+}
+
+func init() {
+	Register(&Format{Name: "json", Extension: ".json", Write: writeJSON})
+	Register(&Format{Name: "csv", Extension: ".csv", Write: writeCSV})
+	Register(&Format{Name: "tsv", Extension: ".tsv", Write: writeTSV})
+}
+
+// Returns the format registered under name
+func Lookup(name string) (*Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %q, must be one of %v", name, Names())
+	}
+	return format, nil
+}
+
+// The names of the registered formats, sorted
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The file a report is written to when none is configured
+func (f *Format) DefaultFile() string {
+	return "results" + f.Extension
+}
+
+func writeJSON(w io.Writer, result *config.SearchResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
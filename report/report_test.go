@@ -0,0 +1,124 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func testSearchResult() *config.SearchResult {
+	expiry := time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &config.SearchResult{
+		SearchPath: "/etc/ssl",
+		TotalFiles: 2,
+		Results: []config.ExtensionResult{
+			{Type: ".pem", Files: []config.FileInfo{{
+				Path: "/etc/ssl/bundle.pem",
+				Certificates: []config.CertificateInfo{
+					{Subject: "CN=www.example.com", Issuer: "CN=Example CA", NotAfter: expiry, SignatureAlgorithm: "SHA256-RSA", Compliant: true},
+					{Subject: "CN=old, O=Example", Issuer: "CN=old, O=Example", NotAfter: expiry, SignatureAlgorithm: "SHA1-RSA", Violations: []config.Finding{
+						{RuleID: "fips-signature-algorithm", Severity: config.SeverityError, Message: "Non-FIPS signature algorithm: SHA1-RSA"},
+						{RuleID: "fips-public-key", Severity: config.SeverityError, Message: "RSA key size 1024 bits is below minimum 2048"},
+					}},
+				},
+			}}},
+			{Type: ".p12", Files: []config.FileInfo{{Path: "/etc/ssl/store.p12"}}},
+		},
+	}
+}
+
+func TestWriteTable_CSV(t *testing.T) {
+	format, err := Lookup("csv")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Write(&buf, testSearchResult()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Report is not valid CSV: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("Expected a header and 3 rows, got %d: %v", len(rows), rows)
+	}
+	if rows[0][0] != "path" || rows[0][7] != "reasons" {
+		t.Errorf("Unexpected header %v", rows[0])
+	}
+	if rows[1][4] != "2027-03-01T12:00:00Z" || rows[1][6] != "compliant" {
+		t.Errorf("Unexpected compliant row %v", rows[1])
+	}
+	// The comma in the subject must survive quoting
+	if rows[2][2] != "CN=old, O=Example" || rows[2][6] != "non-compliant" {
+		t.Errorf("Unexpected non-compliant row %v", rows[2])
+	}
+	if rows[2][7] != "Non-FIPS signature algorithm: SHA1-RSA; RSA key size 1024 bits is below minimum 2048" {
+		t.Errorf("Unexpected reasons %q", rows[2][7])
+	}
+	if rows[3][0] != "/etc/ssl/store.p12" || rows[3][1] != ".p12" || rows[3][2] != "" {
+		t.Errorf("Expected a row for the key store, got %v", rows[3])
+	}
+}
+
+func TestWriteTable_TSV(t *testing.T) {
+	format, err := Lookup("tsv")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.Write(&buf, testSearchResult()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	reader := csv.NewReader(&buf)
+	reader.Comma = '\t'
+	rows, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Report is not valid TSV: %v", err)
+	}
+	if len(rows) != 4 || len(rows[1]) != len(tableHeader) {
+		t.Errorf("Unexpected TSV rows %v", rows)
+	}
+}
+
+func TestWriteTable_EscapesFormulas(t *testing.T) {
+	result := &config.SearchResult{Results: []config.ExtensionResult{{Type: ".pem", Files: []config.FileInfo{{
+		Path: "=HYPERLINK(\"http://evil.example\")",
+		Certificates: []config.CertificateInfo{
+			{Subject: "=cmd|' /C calc'!A0", Issuer: "@SUM(1+1)", SignatureAlgorithm: "+1", Violations: []config.Finding{{Message: "-2+3"}}},
+		},
+	}}}}}
+
+	var buf bytes.Buffer
+	if err := writeCSV(&buf, result); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Report is not valid CSV: %v", err)
+	}
+	for _, column := range []int{0, 2, 3, 5, 7} {
+		if cell := rows[1][column]; cell[0] != '\'' {
+			t.Errorf("Expected %s to be escaped, got %q", tableHeader[column], cell)
+		}
+	}
+	if rows[1][2] != "'=cmd|' /C calc'!A0" {
+		t.Errorf("Expected the subject to be kept after the quote, got %q", rows[1][2])
+	}
+	if rows[1][1] != ".pem" || rows[1][6] != "non-compliant" {
+		t.Errorf("Expected safe cells to be left alone, got %v", rows[1])
+	}
+}
+
+func TestLookup_Unknown(t *testing.T) {
+	if _, err := Lookup("xlsx"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,199 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// The uriBaseId artifact locations are relative to
+	sarifRootID = "SRCROOT"
+)
+
+// The subset of the SARIF 2.1.0 object model findcert produces
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           map[string]any     `json:"properties,omitempty"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           *sarifRegion     `json:"region,omitempty"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+func init() {
+	Register(&Format{Name: "sarif", Extension: ".sarif", Write: writeSARIF})
+}
+
+// Writes every finding of the search as a SARIF 2.1.0 result. Files are
+// located relative to the search path so code scanning tools can map them to
+// the repository that was scanned.
+func writeSARIF(w io.Writer, result *config.SearchResult) error {
+	rules := cmd.Rules()
+	ruleIndex := make(map[string]int, len(rules))
+	driver := sarifDriver{
+		Name:           "findcert",
+		InformationURI: "https://github.com/gkhays/findcert",
+		Rules:          make([]sarifRule, 0, len(rules)),
+	}
+	for i, rule := range rules {
+		ruleIndex[rule.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.Severity)},
+			Properties:           map[string]any{"tags": []string{"security", "cryptography"}},
+		})
+	}
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: driver},
+		Results: []sarifResult{},
+	}
+	if result.SearchPath != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLoc{
+			sarifRootID: {URI: directoryURI(result.SearchPath)},
+		}
+	}
+
+	for _, finding := range cmd.CollectFindings(result) {
+		index, ok := ruleIndex[finding.RuleID]
+		if !ok {
+			continue
+		}
+		sarif := sarifResult{
+			RuleID:    finding.RuleID,
+			RuleIndex: index,
+			Level:     sarifLevel(finding.Severity),
+			Message:   sarifMessage{Text: findingMessage(finding)},
+		}
+		if finding.Path != "" {
+			location := sarifPhysicalLocation{ArtifactLocation: artifactLocation(result.SearchPath, finding.Path)}
+			if finding.Lines != nil {
+				location.Region = &sarifRegion{StartLine: finding.Lines.Start, EndLine: finding.Lines.End}
+			}
+			sarif.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		if finding.Fingerprint != "" {
+			sarif.PartialFingerprints = map[string]string{"certificateFingerprint/v1": finding.Fingerprint}
+		}
+		run.Results = append(run.Results, sarif)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case config.SeverityError:
+		return "error"
+	case config.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// Names the certificate in the message, as a file may hold a whole bundle
+func findingMessage(finding config.LocatedFinding) string {
+	if finding.Subject == "" {
+		return finding.Message
+	}
+	return finding.Message + " (" + finding.Subject + ")"
+}
+
+// Locates path relative to the search root, or by absolute URI outside of it
+func artifactLocation(root, path string) sarifArtifactLoc {
+	if root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return sarifArtifactLoc{URI: escapePath(filepath.ToSlash(rel)), URIBaseID: sarifRootID}
+		}
+	}
+	return sarifArtifactLoc{URI: fileURI(path)}
+}
+
+func directoryURI(path string) string {
+	uri := fileURI(path)
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+	return uri
+}
+
+func fileURI(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		// Windows drive letters
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
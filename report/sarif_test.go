@@ -0,0 +1,115 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+// Scans dir the way the scan command does
+func scanDir(t *testing.T, dir string) *config.SearchResult {
+	t.Helper()
+
+	results, err := cmd.ListCertificates(dir, cmd.ListOptions{})
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	crls := cmd.InspectResults(results)
+	cmd.AuditPermissions(results, nil)
+	return &config.SearchResult{SearchPath: dir, Results: results, CRLs: crls, SearchTime: time.Now()}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1122334455667788),
+		Subject:      pkix.Name{CommonName: "expired.example.com"},
+		DNSNames:     []string{"expired.example.com"},
+		NotBefore:    time.Now().Add(-60 * 24 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	// A certificate and its key committed together, after a comment line
+	var bundle bytes.Buffer
+	bundle.WriteString("# server credentials\n")
+	pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	pem.Encode(&bundle, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.MkdirAll(filepath.Join(dir, "deploy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "deploy", "server.pem"), bundle.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeSARIF(&out, scanDir(t, dir)); err != nil {
+		t.Fatalf("writeSARIF failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log %+v", log)
+	}
+	run := log.Runs[0]
+
+	results := make(map[string]sarifResult)
+	for _, result := range run.Results {
+		results[result.RuleID] = result
+		if run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("Result %s points at rule %d", result.RuleID, result.RuleIndex)
+		}
+	}
+
+	expired, ok := results["validity-period"]
+	if !ok {
+		t.Fatalf("Expected an expired certificate result, got %+v", run.Results)
+	}
+	location := expired.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "deploy/server.pem" || location.ArtifactLocation.URIBaseID != "SRCROOT" {
+		t.Errorf("Unexpected location %+v", location.ArtifactLocation)
+	}
+	if location.Region == nil || location.Region.StartLine != 2 {
+		t.Errorf("Expected the certificate to start on line 2, got %+v", location.Region)
+	}
+	if expired.Level != "error" {
+		t.Errorf("Expected level error, got %s", expired.Level)
+	}
+
+	privateKey, ok := results["private-key-file"]
+	if !ok {
+		t.Fatalf("Expected a private key result, got %+v", run.Results)
+	}
+	region := privateKey.Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine <= location.Region.EndLine {
+		t.Errorf("Expected the key block after the certificate, got %+v", region)
+	}
+}
@@ -0,0 +1,80 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Columns of the CSV and TSV reports
+var tableHeader = []string{"path", "type", "subject", "issuer", "expiry", "algorithm", "compliance", "reasons"}
+
+func writeCSV(w io.Writer, result *config.SearchResult) error {
+	return writeTable(csv.NewWriter(w), result)
+}
+
+func writeTSV(w io.Writer, result *config.SearchResult) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = '\t'
+	return writeTable(writer, result)
+}
+
+// Writes one row per certificate. Files without a parsable certificate, such
+// as key stores, get a single row so the inventory stays complete.
+func writeTable(writer *csv.Writer, result *config.SearchResult) error {
+	if err := writer.Write(tableHeader); err != nil {
+		return err
+	}
+
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			if len(file.Certificates) == 0 {
+				if err := writeRow(writer, []string{file.Path, extResult.Type, "", "", "", "", "", ""}); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, cert := range file.Certificates {
+				row := []string{
+					file.Path,
+					extResult.Type,
+					cert.Subject,
+					cert.Issuer,
+					cert.NotAfter.Format(time.RFC3339),
+					cert.SignatureAlgorithm,
+					cert.ComplianceStatus(),
+					strings.Join(Reasons(&cert), "; "),
+				}
+				if err := writeRow(writer, row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes a row whose cells spreadsheets can not take for formulas. Subjects,
+// issuers and paths come from the files scanned and can not be trusted.
+func writeRow(writer *csv.Writer, row []string) error {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return writer.Write(row)
+}
+
+// The messages of the rules a certificate violates
+func Reasons(cert *config.CertificateInfo) []string {
+	reasons := make([]string, 0, len(cert.Violations))
+	for _, violation := range cert.Violations {
+		reasons = append(reasons, violation.Message)
+	}
+	return reasons
+}
@@ -0,0 +1,224 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+// Version of the checkpoint format, changed whenever a checkpoint written by
+// one version can not be resumed by another
+const checkpointVersion = 3
+
+// The progress of a scan, saved while it runs so that it can be resumed after
+// a crash or interruption without walking the finished directories again
+type Checkpoint struct {
+	Version    int
+	SearchPath string
+	// When the checkpoint was last saved
+	Updated time.Time
+	// Directories walked completely. Directories below them are not listed.
+	Done []string
+	// The files inspected in the finished directories, with their findings
+	Files []CheckpointFile
+	// Whether the scan discarded its files, so that only their paths,
+	// certificates and findings were recorded
+	DiscardedFiles bool
+}
+
+// A file recorded in a checkpoint and the extension it was found with. Scans
+// that discard files record only the path of the FileInfo.
+type CheckpointFile struct {
+	Extension string `json:"extension"`
+	config.FileInfo
+	// The file's certificates, so that a resumed scan can check them against
+	// the CRLs without reading the file again
+	DER [][]byte `json:"certificates_der,omitempty"`
+	// The findings of a discarded file, reported again by a resumed scan
+	Findings []config.LocatedFinding `json:"findings,omitempty"`
+}
+
+// A checkpoint file is a line of JSON with this header, followed by a line for
+// every save with the directories finished since the save before. Saving so
+// costs only what is new, however much of the tree has been scanned.
+type checkpointHeader struct {
+	Version        int       `json:"version"`
+	SearchPath     string    `json:"search_path"`
+	Started        time.Time `json:"started"`
+	DiscardedFiles bool      `json:"discarded_files,omitempty"`
+}
+
+type checkpointRecord struct {
+	Updated time.Time        `json:"updated"`
+	Done    []string         `json:"done_directories"`
+	Files   []CheckpointFile `json:"files"`
+}
+
+// Reads a checkpoint file written by a scan. A last record cut short by a
+// crash is left out.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	line, err := reader.ReadBytes('\n')
+	var header checkpointHeader
+	if err == nil {
+		err = json.Unmarshal(line, &header)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if header.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has version %d, expected %d", path, header.Version, checkpointVersion)
+	}
+
+	checkpoint := &Checkpoint{
+		Version:        header.Version,
+		SearchPath:     header.SearchPath,
+		Updated:        header.Started,
+		Files:          []CheckpointFile{},
+		DiscardedFiles: header.DiscardedFiles,
+	}
+	var done []string
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Records are only complete with their newline
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+		var record checkpointRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+		}
+		checkpoint.Updated = record.Updated
+		done = append(done, record.Done...)
+		checkpoint.Files = append(checkpoint.Files, record.Files...)
+	}
+	checkpoint.Done = outermost(done)
+	return checkpoint, nil
+}
+
+// The directories that are not below another one of dirs, sorted
+func outermost(dirs []string) []string {
+	set := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		set[dir] = true
+	}
+	result := []string{}
+	for dir := range set {
+		below := false
+		for child, parent := dir, filepath.Dir(dir); !below && parent != child; child, parent = parent, filepath.Dir(parent) {
+			below = set[parent]
+		}
+		if !below {
+			result = append(result, dir)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Tracks the directories and files finished since the last save and appends
+// them to the checkpoint file. A file only counts once the directory holding
+// it has been walked completely, as a resumed scan walks the files of an
+// unfinished directory again.
+type progress struct {
+	file *os.File
+	// Whether to record only the paths, certificates and findings of files
+	discard bool
+	// Finished since the last save
+	done  []string
+	files []CheckpointFile
+	// The files of the directories still being walked, by directory
+	pending map[string][]CheckpointFile
+}
+
+// Starts the checkpoint file at path afresh with what resume recorded. It is
+// written to a temporary file first and renamed, so a crash while starting
+// leaves the checkpoint being resumed in place.
+func startProgress(path, root string, discard bool, resume *Checkpoint) (*progress, error) {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.Encode(checkpointHeader{Version: checkpointVersion, SearchPath: root, Started: time.Now(), DiscardedFiles: discard})
+	if resume != nil {
+		if err := encoder.Encode(checkpointRecord{Updated: resume.Updated, Done: resume.Done, Files: resume.Files}); err != nil {
+			return nil, err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return &progress{file: file, discard: discard, pending: make(map[string][]CheckpointFile)}, nil
+}
+
+func (p *progress) add(extension string, file *config.FileInfo, contents *cmd.FileContents) {
+	recorded := CheckpointFile{Extension: extension}
+	if p.discard {
+		recorded.Path = file.Path
+		recorded.Findings = cmd.FileFindings(file)
+	} else {
+		recorded.FileInfo = *file
+	}
+	if contents != nil && len(file.Certificates) > 0 {
+		recorded.DER = contents.Certificates
+	}
+	dir := filepath.Dir(file.Path)
+	p.pending[dir] = append(p.pending[dir], recorded)
+}
+
+// Records dir as finished
+func (p *progress) dirDone(dir string) {
+	p.done = append(p.done, dir)
+	p.files = append(p.files, p.pending[dir]...)
+	delete(p.pending, dir)
+}
+
+// Appends what was finished since the last save to the checkpoint file
+func (p *progress) save() error {
+	if len(p.done) == 0 {
+		return nil
+	}
+	record := checkpointRecord{Updated: time.Now(), Done: p.done, Files: p.files}
+	if record.Files == nil {
+		record.Files = []CheckpointFile{}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// In one write, so that a crash leaves at most a last line without its newline
+	if _, err := p.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	p.done, p.files = nil, nil
+	return nil
+}
+
+func (p *progress) close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,278 @@
+package scan
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func TestScanner_CheckpointAndResume(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFiles(t, filepath.Join(root, dir), dir+".example.com", 365*24*time.Hour)
+	}
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	// Stop once the walk reaches b, after a has been finished
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, _ := New(Options{
+		CheckpointInterval: time.Nanosecond,
+		CheckpointPath:     checkpointPath,
+		OnFile: func(extension string, file *config.FileInfo) error {
+			if filepath.Base(filepath.Dir(file.Path)) == "b" {
+				cancel()
+			}
+			return nil
+		},
+	})
+	if _, err := first.Scan(ctx, root); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	checkpoint, err := LoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if want := []string{filepath.Join(root, "a")}; !reflect.DeepEqual(checkpoint.Done, want) {
+		t.Errorf("Expected %v done, got %v", want, checkpoint.Done)
+	}
+	if len(checkpoint.Files) != 2 {
+		t.Fatalf("Expected the two files of a in the checkpoint, got %d", len(checkpoint.Files))
+	}
+
+	// A resumed scan hands over a's files without walking a again
+	if err := os.RemoveAll(filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	second, _ := New(Options{
+		Resume: checkpoint,
+		OnFile: func(extension string, file *config.FileInfo) error {
+			rel, _ := filepath.Rel(root, file.Path)
+			paths = append(paths, filepath.ToSlash(rel))
+			return nil
+		},
+	})
+	result, err := second.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Resumed scan failed: %v", err)
+	}
+	sort.Strings(paths)
+	want := []string{"a/a.example.com.crt", "a/a.example.com.pem", "b/b.example.com.crt", "b/b.example.com.pem"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Expected files %v, got %v", want, paths)
+	}
+	if result.TotalFiles != 4 || result.Incomplete {
+		t.Errorf("Expected a complete result of 4 files, got %d (incomplete %v)", result.TotalFiles, result.Incomplete)
+	}
+
+	if _, err := second.Scan(context.Background(), t.TempDir()); err == nil {
+		t.Error("Expected resuming a checkpoint of another root to fail")
+	}
+}
+
+func TestScanner_CheckpointAppendsEachDirectoryOnce(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a/x", "a/y", "b"} {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFiles(t, path, filepath.Base(dir)+".example.com", 365*24*time.Hour)
+	}
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+	scanner, _ := New(Options{CheckpointPath: checkpointPath, CheckpointInterval: time.Nanosecond})
+	if _, err := scanner.Scan(context.Background(), root); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	data, err := os.ReadFile(checkpointPath)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("Expected a header and a record per save, got %d lines", len(lines))
+	}
+	// Every save adds only what is new
+	saved := make(map[string]int)
+	for _, line := range lines[1:] {
+		var record checkpointRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid record %s: %v", line, err)
+		}
+		for _, file := range record.Files {
+			saved[file.Path]++
+		}
+	}
+	if len(saved) != 6 {
+		t.Errorf("Expected the 6 files to be saved, got %v", saved)
+	}
+	for path, count := range saved {
+		if count != 1 {
+			t.Errorf("Expected %s to be saved once, got %d", path, count)
+		}
+	}
+
+	// A record cut short by a crash is left out
+	file, err := os.OpenFile(checkpointPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"updated":"2026-`)
+	file.Close()
+	checkpoint, err := LoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if !reflect.DeepEqual(checkpoint.Done, []string{root}) || len(checkpoint.Files) != 6 {
+		t.Errorf("Expected only the root to be listed as done and 6 files, got %v and %d files", checkpoint.Done, len(checkpoint.Files))
+	}
+}
+
+// Writes a tree whose directory a holds a valid and a revoked certificate of
+// a.example.com, and whose directory b holds their CA and the CRL
+func writeRevocationTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca := newTestCA(t, filepath.Join(root, "b", "ca.crt"))
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for name, serial := range map[string]int64{"good.crt": 2001, "revoked.crt": 2002} {
+		if err := os.WriteFile(filepath.Join(root, "a", name), ca.issue(t, serial, "a.example.com", key), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ca.writeCRL(t, filepath.Join(root, "b", "ca.crl"), 2002)
+	return root
+}
+
+// Scans root until the walk reaches b and returns the checkpoint taken, with
+// the files of a deleted so that a resumed scan can not read them
+func checkpointBeforeB(t *testing.T, root string, opts Options) *Checkpoint {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts.CheckpointPath = filepath.Join(t.TempDir(), "checkpoint.json")
+	opts.CheckpointInterval = time.Nanosecond
+	opts.OnFile = func(extension string, file *config.FileInfo) error {
+		if filepath.Base(filepath.Dir(file.Path)) == "b" {
+			cancel()
+		}
+		return nil
+	}
+	scanner, _ := New(opts)
+	if _, err := scanner.Scan(ctx, root); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	checkpoint, err := LoadCheckpoint(opts.CheckpointPath)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if len(checkpoint.Files) != 2 {
+		t.Fatalf("Expected the two files of a in the checkpoint, got %+v", checkpoint)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+	return checkpoint
+}
+
+func TestScanner_ResumeChecksRestoredCertificatesWithoutReading(t *testing.T) {
+	root := writeRevocationTree(t)
+	checkpoint := checkpointBeforeB(t, root, Options{})
+	for _, recorded := range checkpoint.Files {
+		if len(recorded.Certificates) != 1 || len(recorded.DER) != 1 {
+			t.Fatalf("Expected the certificate and its DER recorded for %s, got %+v", recorded.Path, recorded)
+		}
+	}
+
+	scanner, _ := New(Options{Resume: checkpoint})
+	result, err := scanner.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Resumed scan failed: %v", err)
+	}
+	revoked := make(map[string]bool)
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			for _, cert := range file.Certificates {
+				revoked[filepath.Base(file.Path)] = cert.Revoked
+			}
+		}
+	}
+	if !revoked["revoked.crt"] || revoked["good.crt"] {
+		t.Errorf("Expected only revoked.crt to be revoked by the CRL found after resuming, got %v", revoked)
+	}
+}
+
+func TestScanner_ResumeDiscardedFiles(t *testing.T) {
+	root := writeRevocationTree(t)
+	settings := config.DefaultSettings()
+	settings.Policy.Hosts = []string{"a.example.com"}
+	checkpoint := checkpointBeforeB(t, root, Options{Settings: settings, DiscardFiles: true})
+	if !checkpoint.DiscardedFiles {
+		t.Error("Expected the checkpoint to be marked as one of discarded files")
+	}
+	for _, recorded := range checkpoint.Files {
+		if recorded.Path == "" || len(recorded.Certificates) != 0 || len(recorded.DER) != 1 {
+			t.Errorf("Expected only the path and DER of %s to be recorded, got %+v", recorded.Path, recorded)
+		}
+	}
+
+	keeping, _ := New(Options{Settings: settings, Resume: checkpoint})
+	if _, err := keeping.Scan(context.Background(), root); err == nil {
+		t.Error("Expected a scan that keeps files to refuse the checkpoint")
+	}
+
+	var files []string
+	findings := make(map[string]int)
+	scanner, _ := New(Options{
+		Settings:     settings,
+		DiscardFiles: true,
+		Resume:       checkpoint,
+		OnFile: func(extension string, file *config.FileInfo) error {
+			files = append(files, filepath.Base(file.Path))
+			return nil
+		},
+		OnFinding: func(finding config.LocatedFinding) error {
+			findings[finding.RuleID]++
+			return nil
+		},
+	})
+	result, err := scanner.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Resumed scan failed: %v", err)
+	}
+	sort.Strings(files)
+	if !reflect.DeepEqual(files, []string{"ca.crl", "ca.crt"}) {
+		t.Errorf("Expected only the files of b to be handed over, got %v", files)
+	}
+	if result.TotalFiles != 4 {
+		t.Errorf("Expected the restored files to be counted, got %d", result.TotalFiles)
+	}
+	// The recorded findings of a are reported again, and a's valid certificate
+	// still covers the host
+	if findings["revoked"] != 1 || findings["serial-number-short"] < 2 || findings["host-not-covered"] != 0 {
+		t.Errorf("Unexpected findings %v", findings)
+	}
+}
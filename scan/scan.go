@@ -0,0 +1,325 @@
+// Package scan searches directory trees for certificates, key stores and
+// CRLs and checks them, for programs that embed findcert. It never writes
+// to standard output or exits; results come back as values and through
+// callbacks.
+//
+//	scanner, err := scan.New(scan.Options{
+//		Settings: config.DefaultSettings(),
+//		OnFinding: func(finding config.LocatedFinding) error {
+//			log.Printf("%s: %s", finding.Path, finding.Message)
+//			return nil
+//		},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := scanner.Scan(ctx, "/etc/ssl")
+package scan
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"org.gkh/findcert/cache"
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+)
+
+// How a Scanner searches and what it tells the caller while it runs
+type Options struct {
+	// Extensions, exclusions, thresholds and policy. The zero value means
+	// config.DefaultSettings().
+	Settings config.Settings
+	// Accepted findings, nil for none
+	Baseline *config.Baseline
+	// Leaves the files out of the result, for callers that handle each file
+	// in OnFile and do not want the whole tree kept in memory
+	DiscardFiles bool
+	// Called with each file once it has been inspected, before the CRLs that
+	// may revoke its certificates are known. An error stops the scan.
+	OnFile func(extension string, file *config.FileInfo) error
+	// Called with every finding that is neither disabled nor accepted by the
+	// baseline, as soon as it is known. An error stops the scan.
+	OnFinding func(finding config.LocatedFinding) error
+	// Continues the scan a checkpoint was taken of. Its files are handed over
+	// again as if they had just been inspected and its finished directories
+	// are not walked again. Checkpoints of scans with DiscardFiles only
+	// recorded the findings of the files, which are reported again without
+	// calling OnFile, and can only be resumed with DiscardFiles.
+	Resume *Checkpoint
+	// Where the progress of the scan is saved, at most once every
+	// CheckpointInterval and once more if the scan stops early, empty for
+	// nowhere. The file is started afresh by every scan, with what Resume
+	// recorded, so scans at the same time need different files. An error
+	// saving stops the scan.
+	CheckpointPath string
+	// One minute when zero
+	CheckpointInterval time.Duration
+	// Where unchanged files are taken from instead of being read again, nil
+	// to read every file. The caller saves it once the scan is done.
+	Cache *cache.Cache
+}
+
+const defaultCheckpointInterval = time.Minute
+
+// Runs scans with fixed options. A Scanner can run any number of scans, one
+// after the other or at the same time.
+type Scanner struct {
+	opts    Options
+	checker *cmd.Checker
+}
+
+// Checks the settings and returns a scanner for them
+func New(opts Options) (*Scanner, error) {
+	if reflect.ValueOf(opts.Settings).IsZero() {
+		opts.Settings = config.DefaultSettings()
+	}
+	if err := opts.Settings.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.CheckpointInterval == 0 {
+		opts.CheckpointInterval = defaultCheckpointInterval
+	}
+	return &Scanner{opts: opts, checker: cmd.NewChecker(opts.Settings)}, nil
+}
+
+// Searches root and returns what was found. Revocations found through CRLs,
+// CRL problems and uncovered hosts are only known once every file has been
+// seen and are reported after the last file. When ctx is done the scan stops
+// and returns what it found so far, marked incomplete, together with ctx's
+// error. Host coverage is left out of an incomplete result, as the hosts may
+// be covered by files that were never reached. With CheckpointPath set, a
+// checkpoint is also taken when the scan stops early for any reason.
+func (s *Scanner) Scan(ctx context.Context, root string) (*config.SearchResult, error) {
+	return s.ScanWith(ctx, root, nil, nil)
+}
+
+// Scan, checking what is found against certificates and CRLs from outside
+// root as well, such as those of the rest of a tree one file of which
+// changed. CRLs found below root may be signed by the issuers, and
+// certificates found below root are checked against the CRLs that an issuer
+// or a certificate found below root signed. Neither is reported.
+func (s *Scanner) ScanWith(ctx context.Context, root string, issuers []*x509.Certificate, knownCRLs []*x509.RevocationList) (*config.SearchResult, error) {
+	settings := s.opts.Settings
+	baseline := s.opts.Baseline
+	start := time.Now()
+
+	extensions := settings.Extensions
+	results := make([]config.ExtensionResult, len(extensions))
+	index := make(map[string]int, len(extensions))
+	for i, ext := range extensions {
+		results[i] = config.ExtensionResult{Type: ext}
+		index[ext] = i
+	}
+	// Where each kept file is, to apply revocations to it
+	kept := make(map[string][2]int)
+
+	inspector := s.checker.NewInspector()
+	inspector.AddKnown(issuers, knownCRLs)
+	hosts := cmd.NewHostTracker(settings.Policy.Hosts)
+	totalFiles := 0
+
+	// Hands an inspected file to the caller and keeps it for the result
+	handle := func(extension string, file *config.FileInfo) error {
+		hosts.Add(file)
+		totalFiles++
+
+		if s.opts.OnFile != nil {
+			if err := s.opts.OnFile(extension, file); err != nil {
+				return err
+			}
+		}
+		if err := s.emit(cmd.FileFindings(file)); err != nil {
+			return err
+		}
+		if !s.opts.DiscardFiles {
+			i := index[extension]
+			kept[file.Path] = [2]int{i, len(results[i].Files)}
+			results[i].Files = append(results[i].Files, *file)
+		}
+		return nil
+	}
+
+	listOpts := cmd.ListOptions{Extensions: extensions, Exclude: settings.Exclude}
+	resume := s.opts.Resume
+	if resume != nil {
+		if resume.SearchPath != root {
+			return nil, fmt.Errorf("checkpoint is of a scan of %s, not %s", resume.SearchPath, root)
+		}
+		if resume.DiscardedFiles && !s.opts.DiscardFiles {
+			return nil, errors.New("checkpoint only recorded the findings of its files, it can not be resumed by a scan that keeps them")
+		}
+		for _, recorded := range resume.Files {
+			file := recorded.FileInfo
+			if _, ok := index[recorded.Extension]; !ok {
+				continue
+			}
+			inspector.Restore(recorded.Extension, &file, recorded.DER)
+			if resume.DiscardedFiles {
+				// Only the findings are left to hand over
+				hosts.Add(&file)
+				totalFiles++
+				if err := s.emit(recorded.Findings); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			cmd.SuppressFile(&file, baseline, start)
+			if err := handle(recorded.Extension, &file); err != nil {
+				return nil, err
+			}
+		}
+		skip := make(map[string]bool, len(resume.Done))
+		for _, dir := range resume.Done {
+			skip[dir] = true
+		}
+		listOpts.SkipDir = func(path string) bool { return skip[path] }
+	}
+
+	var tracker *progress
+	if s.opts.CheckpointPath != "" {
+		var err error
+		if tracker, err = startProgress(s.opts.CheckpointPath, root, s.opts.DiscardFiles, resume); err != nil {
+			return nil, err
+		}
+		defer tracker.close()
+		lastCheckpoint := time.Now()
+		listOpts.DirDone = func(dir string) error {
+			tracker.dirDone(dir)
+			if time.Since(lastCheckpoint) < s.opts.CheckpointInterval {
+				return nil
+			}
+			lastCheckpoint = time.Now()
+			return tracker.save()
+		}
+	}
+
+	var cacheStats *config.CacheStats
+	if s.opts.Cache != nil {
+		cacheStats = &config.CacheStats{}
+	}
+
+	err := cmd.WalkCertificates(ctx, root, listOpts, func(extension string, file config.FileInfo) error {
+		contents, err := s.read(extension, file.Path, cacheStats)
+		if err != nil {
+			file.Error = err.Error()
+		}
+		inspector.InspectContents(extension, &file, contents)
+		if contents != nil {
+			s.checker.AuditContents(&file, contents)
+		}
+		cmd.SuppressFile(&file, baseline, start)
+		if tracker != nil {
+			tracker.add(extension, &file, contents)
+		}
+		return handle(extension, &file)
+	})
+	stopped := ctx.Err()
+	if err != nil && tracker != nil {
+		// Save what was finished before stopping, to resume from there
+		if saveErr := tracker.save(); saveErr != nil {
+			if stopped != nil {
+				stopped = errors.Join(stopped, saveErr)
+			} else {
+				err = errors.Join(err, saveErr)
+			}
+		}
+	}
+	if err != nil && stopped == nil {
+		return nil, fmt.Errorf("failed to walk directory tree: %w", err)
+	}
+
+	crls, revocations := inspector.Finish()
+	revoked := make(map[string]bool)
+	var late []config.LocatedFinding
+	for _, revocation := range revocations {
+		revoked[revocation.Fingerprint] = true
+		if at, ok := kept[revocation.Path]; ok {
+			file := &results[at[0]].Files[at[1]]
+			revocation.Apply(&file.Certificates[revocation.Index])
+			cmd.SuppressFile(file, baseline, start)
+		}
+		late = append(late, revocation.Located())
+	}
+	late = append(late, cmd.CRLFindings(crls)...)
+	var coverage []config.HostCoverage
+	if stopped == nil {
+		coverage = hosts.Coverage(revoked)
+		late = append(late, cmd.HostFindings(coverage)...)
+	}
+
+	result := &config.SearchResult{
+		SchemaVersion: config.SchemaVersion,
+		SearchPath:    root,
+		TotalFiles:    totalFiles,
+		CRLs:          crls,
+		Hosts:         coverage,
+		DisabledRules: settings.Policy.DisabledRules,
+		Incomplete:    stopped != nil,
+		Cache:         cacheStats,
+	}
+	if !s.opts.DiscardFiles {
+		result.Results = results
+	}
+
+	var reported []config.LocatedFinding
+	for _, finding := range late {
+		suppression := baseline.Suppression(finding, start)
+		switch {
+		case suppression == nil:
+			reported = append(reported, finding)
+		case finding.RuleID != "revoked" || s.opts.DiscardFiles:
+			// Suppressed revocations are otherwise kept with their file
+			result.Suppressed = append(result.Suppressed, config.SuppressedFinding{
+				LocatedFinding: finding,
+				Justification:  suppression.Justification,
+				Expires:        suppression.Expires,
+			})
+		}
+	}
+	if err := s.emit(reported); err != nil {
+		return nil, err
+	}
+
+	result.SearchTime = time.Now()
+	result.Duration = result.SearchTime.Sub(start).Seconds()
+	return result, stopped
+}
+
+// The findings of a result that are neither disabled nor accepted by the baseline
+func (s *Scanner) Findings(result *config.SearchResult) []config.LocatedFinding {
+	return s.checker.Filter(cmd.CollectFindings(result))
+}
+
+// Reads what the file holds, through the cache when there is one
+func (s *Scanner) read(extension, path string, stats *config.CacheStats) (*cmd.FileContents, error) {
+	if s.opts.Cache == nil {
+		return cmd.ReadContents(extension, path)
+	}
+	contents, hit, err := s.opts.Cache.Read(extension, path)
+	if err != nil {
+		return nil, err
+	}
+	if hit {
+		stats.Hits++
+	} else {
+		stats.Misses++
+	}
+	return contents, nil
+}
+
+func (s *Scanner) emit(findings []config.LocatedFinding) error {
+	if s.opts.OnFinding == nil {
+		return nil
+	}
+	for _, finding := range s.checker.Filter(findings) {
+		if err := s.opts.OnFinding(finding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
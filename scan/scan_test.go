@@ -0,0 +1,329 @@
+package scan
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+// Writes a certificate for name, valid for the given time from now, and its private key
+func writeTestFiles(t *testing.T, dir, name string, validFor time.Duration) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+}
+
+// A CA that issues certificates and CRLs for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Creates a CA and writes its certificate to path
+func newTestCA(t *testing.T, path string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// A PEM certificate for name with the serial and the public half of key
+func (ca *testCA) issue(t *testing.T, serial int64, name string, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// Writes a DER CRL revoking the serials to path
+func (ca *testCA) writeCRL(t *testing.T, path string, serials ...int64) {
+	t.Helper()
+	var revoked []x509.RevocationListEntry
+	for _, serial := range serials {
+		revoked = append(revoked, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now().Add(-time.Minute)})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Hour),
+		NextUpdate:                time.Now().Add(24 * time.Hour),
+		RevokedCertificateEntries: revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	if err := os.WriteFile(path, der, 0644); err != nil {
+		t.Fatalf("Failed to write CRL: %v", err)
+	}
+}
+
+func TestScanner_Scan(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "www.example.com", 365*24*time.Hour)
+
+	var files []string
+	var findings []config.LocatedFinding
+	scanner, err := New(Options{
+		OnFile: func(extension string, file *config.FileInfo) error {
+			files = append(files, file.Path)
+			return nil
+		},
+		OnFinding: func(finding config.LocatedFinding) error {
+			findings = append(findings, finding)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	result, err := scanner.Scan(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.TotalFiles != 2 || len(files) != 2 {
+		t.Errorf("Expected the certificate and the key, got %d files and callbacks for %v", result.TotalFiles, files)
+	}
+	if result.SchemaVersion != config.SchemaVersion || result.SearchTime.IsZero() {
+		t.Errorf("Expected a complete result, got %+v", result)
+	}
+	if len(findings) != 1 || findings[0].RuleID != "private-key-file" {
+		t.Errorf("Expected the private key finding, got %+v", findings)
+	}
+	if got := scanner.Findings(result); len(got) != len(findings) {
+		t.Errorf("Expected the result to hold the %d findings reported, got %d", len(findings), len(got))
+	}
+}
+
+func TestScanner_DiscardFilesAndBaseline(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "www.example.com", 365*24*time.Hour)
+
+	settings := config.DefaultSettings()
+	settings.Policy.Hosts = []string{"api.example.com"}
+	baseline := &config.Baseline{Suppressions: []config.Suppression{{
+		RuleID: "private-key-file", Path: filepath.Join(dir, "www.example.com.pem"),
+		Justification: "Test key", Expires: "2999-12-31",
+	}}}
+
+	var findings []string
+	scanner, err := New(Options{
+		Settings:     settings,
+		Baseline:     baseline,
+		DiscardFiles: true,
+		OnFinding: func(finding config.LocatedFinding) error {
+			findings = append(findings, finding.RuleID)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	result, err := scanner.Scan(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.Results != nil || result.TotalFiles != 2 {
+		t.Errorf("Expected the files to be counted but not kept, got %+v", result)
+	}
+	if len(findings) != 1 || findings[0] != "host-not-covered" {
+		t.Errorf("Expected only the uncovered host, got %v", findings)
+	}
+}
+
+func TestScanner_CallbackErrorStops(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "www.example.com", 365*24*time.Hour)
+
+	stop := errors.New("stop")
+	scanner, _ := New(Options{OnFile: func(string, *config.FileInfo) error { return stop }})
+	if _, err := scanner.Scan(context.Background(), dir); !errors.Is(err, stop) {
+		t.Errorf("Expected the callback's error, got %v", err)
+	}
+}
+
+func TestScanner_Canceled(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "www.example.com", 365*24*time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scanner, _ := New(Options{})
+	if _, err := scanner.Scan(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestScanner_CanceledKeepsPartialResult(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, "a.example.com", 365*24*time.Hour)
+	writeTestFiles(t, dir, "b.example.com", 365*24*time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	settings := config.DefaultSettings()
+	settings.Policy.Hosts = []string{"missing.example.com"}
+	var findings []string
+	scanner, _ := New(Options{
+		Settings: settings,
+		OnFile: func(string, *config.FileInfo) error {
+			cancel()
+			return nil
+		},
+		OnFinding: func(finding config.LocatedFinding) error {
+			findings = append(findings, finding.RuleID)
+			return nil
+		},
+	})
+
+	result, err := scanner.Scan(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if result == nil || !result.Incomplete {
+		t.Fatalf("Expected a partial result marked incomplete, got %+v", result)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected the one file seen before the cancel, got %d", result.TotalFiles)
+	}
+	if len(result.Hosts) != 0 {
+		t.Errorf("Expected no host coverage in an incomplete result, got %v", result.Hosts)
+	}
+	for _, rule := range findings {
+		if rule == "host-not-covered" {
+			t.Error("Expected no uncovered host findings from an incomplete scan")
+		}
+	}
+}
+
+func TestScanner_RevokedKeyFileSuppressedOnce(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, filepath.Join(dir, "ca.crt"))
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var data []byte
+	// Two revoked certificates in the one file with the key
+	for serial := int64(1001); serial <= 1002; serial++ {
+		data = append(data, ca.issue(t, serial, "revoked.example.com", key)...)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+	keyFile := filepath.Join(dir, "revoked.pem")
+	if err := os.WriteFile(keyFile, data, 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	ca.writeCRL(t, filepath.Join(dir, "ca.crl"), 1001, 1002)
+
+	baseline := &config.Baseline{Suppressions: []config.Suppression{
+		{RuleID: "private-key-file", Path: keyFile, Justification: "Test key", Expires: "2999-12-31"},
+		{RuleID: "revoked", Path: keyFile, Justification: "Being replaced", Expires: "2999-12-31"},
+	}}
+	var findings []string
+	scanner, _ := New(Options{Baseline: baseline, OnFinding: func(finding config.LocatedFinding) error {
+		findings = append(findings, finding.RuleID)
+		return nil
+	}})
+	result, err := scanner.Scan(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	var file *config.FileInfo
+	for i := range result.Results {
+		for j := range result.Results[i].Files {
+			if result.Results[i].Files[j].Path == keyFile {
+				file = &result.Results[i].Files[j]
+			}
+		}
+	}
+	if file == nil || len(file.Certificates) != 2 {
+		t.Fatalf("Expected the key file with both certificates in the results, got %+v", file)
+	}
+	for _, cert := range file.Certificates {
+		if !cert.Revoked {
+			t.Errorf("Expected %s to be revoked", cert.Fingerprint)
+		}
+	}
+	counts := make(map[string]int)
+	for _, suppressed := range file.Suppressed {
+		counts[suppressed.RuleID]++
+	}
+	if len(file.Suppressed) != 3 || counts["private-key-file"] != 1 || counts["revoked"] != 2 {
+		t.Errorf("Expected the key finding once and each revocation once, got %+v", file.Suppressed)
+	}
+	for _, rule := range findings {
+		if rule == "revoked" || rule == "private-key-file" {
+			t.Errorf("Expected the accepted %s finding not to be reported", rule)
+		}
+	}
+}
+
+func TestNew_InvalidSettings(t *testing.T) {
+	settings := config.DefaultSettings()
+	settings.Policy.FailOn = "sometimes"
+	if _, err := New(Options{Settings: settings}); err == nil {
+		t.Error("Expected invalid settings to be rejected")
+	}
+}
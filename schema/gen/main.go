@@ -0,0 +1,23 @@
+// Regenerates results.schema.json; run through go generate in the schema package
+package main
+
+import (
+	"log"
+	"os"
+
+	"org.gkh/findcert/schema"
+)
+
+func main() {
+	docs, err := schema.ParseDocs("../config")
+	if err != nil {
+		log.Fatalf("failed to read doc comments: %v", err)
+	}
+	data, err := schema.GenerateResults(docs)
+	if err != nil {
+		log.Fatalf("failed to generate schema: %v", err)
+	}
+	if err := os.WriteFile("results.schema.json", data, 0644); err != nil {
+		log.Fatalf("failed to write schema: %v", err)
+	}
+}
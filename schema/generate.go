@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Builds JSON Schemas from Go types the way encoding/json marshals them
+type generator struct {
+	docs map[string]string
+	defs map[string]any
+}
+
+// Generates a JSON Schema (draft 2020-12) for values of t as encoding/json
+// writes them. Named struct types become $defs. Descriptions are taken from
+// docs, keyed by type name and by "Type.Field".
+func Generate(t reflect.Type, title string, docs map[string]string) ([]byte, error) {
+	g := &generator{docs: docs, defs: make(map[string]any)}
+
+	root := g.schemaFor(t, false)
+	ref, ok := root["$ref"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a named struct type", t)
+	}
+
+	// The root type is inlined, the other structs stay definitions
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	document := g.defs[name].(map[string]any)
+	delete(g.defs, name)
+	document["$schema"] = draft
+	document["title"] = title
+	if len(g.defs) > 0 {
+		document["$defs"] = g.defs
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// The schema of a value of type t; nullable values may also be null
+func (g *generator) schemaFor(t reflect.Type, nullable bool) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		return g.schemaFor(t.Elem(), nullable)
+	}
+
+	var schema map[string]any
+	switch {
+	case t == timeType:
+		schema = map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		g.define(t)
+		schema = map[string]any{"$ref": "#/$defs/" + t.Name()}
+		if nullable {
+			return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+		}
+		return schema
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema = map[string]any{"type": "array", "items": g.schemaFor(t.Elem(), false)}
+	case t.Kind() == reflect.Map:
+		schema = map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem(), false)}
+	case t.Kind() == reflect.String:
+		schema = map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		schema = map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = map[string]any{"type": "number"}
+	default:
+		schema = map[string]any{}
+	}
+
+	if nullable {
+		if typ, ok := schema["type"].(string); ok {
+			schema["type"] = []string{typ, "null"}
+		}
+	}
+	return schema
+}
+
+// Adds the struct to $defs, once
+func (g *generator) define(t reflect.Type) {
+	if _, ok := g.defs[t.Name()]; ok {
+		return
+	}
+	def := map[string]any{"type": "object", "additionalProperties": false}
+	// Recursive types find the definition while it is being built
+	g.defs[t.Name()] = def
+	if doc := g.docs[t.Name()]; doc != "" {
+		def["description"] = doc
+	}
+
+	properties := make(map[string]any)
+	required := []string{}
+	g.addFields(t, t.Name(), properties, &required)
+	def["properties"] = properties
+	if len(required) > 0 {
+		def["required"] = required
+	}
+}
+
+func (g *generator) addFields(t reflect.Type, owner string, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		// Embedded structs without a name are flattened by encoding/json
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			g.addFields(embedded, embedded.Name(), properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		omitEmpty := strings.Contains(options, "omitempty")
+		kind := field.Type.Kind()
+		// Without omitempty nil pointers, slices and maps are written as null
+		nullable := !omitEmpty && (kind == reflect.Pointer || kind == reflect.Slice || kind == reflect.Map)
+
+		property := g.schemaFor(field.Type, nullable)
+		if doc := g.docs[owner+"."+field.Name]; doc != "" {
+			if _, isRef := property["$ref"]; isRef {
+				// Keywords next to $ref are allowed since draft 2019-09
+				property = map[string]any{"$ref": property["$ref"], "description": doc}
+			} else {
+				property["description"] = doc
+			}
+		}
+		properties[name] = property
+		if !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Reads the doc comments of the types and struct fields declared in the Go
+// files of dir, keyed as Generate expects
+func ParseDocs(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	docs := make(map[string]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				addTypeDocs(docs, gen)
+			}
+		}
+	}
+	return docs, nil
+}
+
+func addTypeDocs(docs map[string]string, gen *ast.GenDecl) {
+	for _, spec := range gen.Specs {
+		typeSpec := spec.(*ast.TypeSpec)
+		doc := typeSpec.Doc
+		if doc == nil {
+			doc = gen.Doc
+		}
+		if text := commentText(doc); text != "" {
+			docs[typeSpec.Name.Name] = text
+		}
+
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range structType.Fields.List {
+			text := commentText(field.Doc)
+			if text == "" {
+				text = commentText(field.Comment)
+			}
+			if text == "" {
+				continue
+			}
+			for _, name := range field.Names {
+				docs[typeSpec.Name.Name+"."+name.Name] = text
+			}
+		}
+	}
+}
+
+func commentText(group *ast.CommentGroup) string {
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
@@ -0,0 +1,20 @@
+package schema
+
+import (
+	_ "embed"
+	"reflect"
+
+	"org.gkh/findcert/config"
+)
+
+//go:generate go run ./gen
+
+// The JSON Schema of results.json, generated from config.SearchResult
+//
+//go:embed results.schema.json
+var Results []byte
+
+// Generates the results schema from the config types and their doc comments
+func GenerateResults(docs map[string]string) ([]byte, error) {
+	return Generate(reflect.TypeOf(config.SearchResult{}), "findcert scan results "+config.SchemaVersion, docs)
+}
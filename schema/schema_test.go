@@ -0,0 +1,296 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/cache"
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/scan"
+)
+
+func TestResultsSchemaUpToDate(t *testing.T) {
+	docs, err := ParseDocs("../config")
+	if err != nil {
+		t.Fatalf("ParseDocs failed: %v", err)
+	}
+	generated, err := GenerateResults(docs)
+	if err != nil {
+		t.Fatalf("GenerateResults failed: %v", err)
+	}
+	if !bytes.Equal(generated, Results) {
+		t.Error("results.schema.json is out of date, run go generate ./schema")
+	}
+}
+
+func TestResultsValidateAgainstSchema(t *testing.T) {
+	dir := t.TempDir()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(0x0102030405060708),
+		Subject:               pkix.Name{CommonName: "schema.example.com"},
+		DNSNames:              []string{"schema.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(3),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, cert, key)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	files := map[string][]byte{
+		"ca.pem":     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"ca.crl":     crl,
+		"broken.crl": []byte("not a CRL"),
+		"key.pem":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := config.DefaultSettings()
+	settings.Policy.Hosts = []string{"schema.example.com", "other.example.com"}
+	settings.Policy.DisabledRules = []string{"expiring-soon"}
+	baseline := &config.Baseline{Suppressions: []config.Suppression{
+		{RuleID: "private-key-file", Path: filepath.Join(dir, "key.pem"), Justification: "Test key", Expires: "2999-12-31"},
+		{RuleID: "crl-invalid", Path: filepath.Join(dir, "broken.crl"), Justification: "Test CRL", Expires: "2999-12-31"},
+	}}
+	fileCache, err := cache.Open(filepath.Join(t.TempDir(), "cache.json"), false)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	scanner, err := scan.New(scan.Options{Settings: settings, Baseline: baseline, Cache: fileCache})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	complete, err := scanner.Scan(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	// A scan stopped after its first file, reading from the cache filled above
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopper, err := scan.New(scan.Options{Settings: settings, Baseline: baseline, Cache: fileCache,
+		OnFile: func(string, *config.FileInfo) error {
+			cancel()
+			return nil
+		}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	partial, err := stopper.Scan(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the scan to be canceled, got %v", err)
+	}
+
+	for _, result := range []*config.SearchResult{complete, partial} {
+		validateResult(t, result)
+	}
+	if len(complete.Suppressed) == 0 || len(complete.DisabledRules) == 0 || len(complete.CRLs) == 0 ||
+		len(complete.Hosts) == 0 || complete.Cache == nil || complete.Duration == 0 {
+		t.Errorf("Expected the complete scan to fill every optional field, got %+v", complete)
+	}
+	fileSuppressed := false
+	for _, extension := range complete.Results {
+		for _, file := range extension.Files {
+			fileSuppressed = fileSuppressed || len(file.Suppressed) > 0
+		}
+	}
+	if !fileSuppressed {
+		t.Error("Expected the accepted key finding to be kept with its file")
+	}
+	if !partial.Incomplete || partial.Cache == nil || partial.Cache.Hits == 0 {
+		t.Errorf("Expected an incomplete scan with cache hits, got %+v", partial)
+	}
+}
+
+// Checks the JSON encoding of result against the schema, and that the schema
+// rejects a property it does not describe
+func validateResult(t *testing.T, result *config.SearchResult) {
+	t.Helper()
+
+	output, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document, instance any
+	if err := json.Unmarshal(Results, &document); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+	if err := json.Unmarshal(output, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &validator{root: document.(map[string]any)}
+	v.validate("$", v.root, instance)
+	for _, problem := range v.problems {
+		t.Error(problem)
+	}
+
+	// The schema must reject what it does not describe
+	instance.(map[string]any)["unexpected"] = true
+	v = &validator{root: document.(map[string]any)}
+	v.validate("$", v.root, instance)
+	if len(v.problems) == 0 {
+		t.Error("Expected an unknown property to be rejected")
+	}
+}
+
+// Checks an instance against the keywords the generated schema uses
+type validator struct {
+	root     map[string]any
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(path string, schema map[string]any, instance any) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		def, ok := v.root["$defs"].(map[string]any)[name].(map[string]any)
+		if !ok {
+			v.fail(path, "unresolved reference %s", ref)
+			return
+		}
+		v.validate(path, def, instance)
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		for _, option := range anyOf {
+			attempt := &validator{root: v.root}
+			attempt.validate(path, option.(map[string]any), instance)
+			if len(attempt.problems) == 0 {
+				return
+			}
+		}
+		v.fail(path, "matches none of anyOf")
+		return
+	}
+
+	if types, ok := schema["type"]; ok && !v.hasType(types, instance) {
+		v.fail(path, "expected type %v, got %T", types, instance)
+		return
+	}
+	if schema["format"] == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, instance.(string)); err != nil {
+			v.fail(path, "invalid date-time %q", instance)
+		}
+	}
+
+	switch value := instance.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := value[name]; !ok {
+				v.fail(path, "missing required property %s", name)
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]any); ok {
+				v.validate(path+"."+name, property, value[name])
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					v.fail(path, "unexpected property %s", name)
+				}
+			case map[string]any:
+				v.validate(path+"."+name, additional, value[name])
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+			}
+		}
+	}
+}
+
+func (v *validator) hasType(types any, instance any) bool {
+	names := stringList(types)
+	if name, ok := types.(string); ok {
+		names = []string{name}
+	}
+	for _, name := range names {
+		switch value := instance.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && value == float64(int64(value))) {
+				return true
+			}
+		case []any:
+			if name == "array" {
+				return true
+			}
+		case map[string]any:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stringList(value any) []string {
+	list, _ := value.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
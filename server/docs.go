@@ -0,0 +1,94 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+// The parts of the OpenAPI document the docs page shows
+type apiDocument struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"info"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+}
+
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 56rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.op { border: 1px solid #ddd; border-radius: 4px; padding: .5rem 1rem; margin: .5rem 0; }
+.method { display: inline-block; min-width: 4rem; font-weight: bold; }
+code { font-size: 1rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Description}}</p>
+<p>The full description is in <a href="/openapi.json">openapi.json</a>.</p>
+{{range .Operations}}<div class="op">
+<p><span class="method">{{.Method}}</span> <code>{{.Path}}</code> {{.Summary}}</p>
+{{if .Description}}<p>{{.Description}}</p>{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// Renders the OpenAPI document as a page listing every operation
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	var doc apiDocument
+	if err := json.Unmarshal(openAPI, &doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var operations []apiOperation
+	for path, item := range doc.Paths {
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			op := apiOperation{Method: method, Path: path}
+			json.Unmarshal(raw, &op)
+			operations = append(operations, op)
+		}
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Path != operations[j].Path {
+			return operations[i].Path < operations[j].Path
+		}
+		return operations[i].Method > operations[j].Method
+	})
+	for i := range operations {
+		operations[i].Method = strings.ToUpper(operations[i].Method)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	docsTemplate.Execute(w, struct {
+		Title       string
+		Description string
+		Operations  []apiOperation
+	}{doc.Info.Title, doc.Info.Description, operations})
+}
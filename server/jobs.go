@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/scan"
+)
+
+// Job states
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// What a client asks a scan job to do. Lists left empty keep the server's settings.
+type ScanRequest struct {
+	Path          string   `json:"path"`
+	Extensions    []string `json:"extensions,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Hosts         []string `json:"hosts,omitempty"`
+	AllowedOwners []string `json:"allowed_owners,omitempty"`
+}
+
+// A scan job as clients see it
+type Job struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	Request    ScanRequest `json:"request"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// Set once the job has succeeded
+	TotalFiles *int `json:"total_files,omitempty"`
+	Findings   *int `json:"findings,omitempty"`
+}
+
+type job struct {
+	Job
+	settings config.Settings
+	result   *config.SearchResult
+	findings *int
+	cancel   context.CancelFunc
+}
+
+// Runs scan jobs, at most limit at a time, and keeps the last max jobs
+type jobQueue struct {
+	baseline *config.Baseline
+	slots    chan struct{}
+	max      int
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string
+	// Canceled when the queue is closed, ending every job
+	ctx   context.Context
+	close context.CancelFunc
+	wg    sync.WaitGroup
+}
+
+var errQueueFull = errors.New("too many jobs are queued or running")
+
+func newJobQueue(limit, max int, baseline *config.Baseline) *jobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobQueue{
+		baseline: baseline,
+		slots:    make(chan struct{}, limit),
+		max:      max,
+		jobs:     make(map[string]*job),
+		ctx:      ctx,
+		close:    cancel,
+	}
+}
+
+// Queues a scan with the settings and starts it once a slot is free
+func (q *jobQueue) submit(request ScanRequest, settings config.Settings) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.evict() {
+		return Job{}, errQueueFull
+	}
+
+	ctx, cancel := context.WithCancel(q.ctx)
+	j := &job{
+		Job:      Job{ID: newJobID(), Status: StatusQueued, Request: request, CreatedAt: time.Now().UTC()},
+		settings: settings,
+		cancel:   cancel,
+	}
+	q.jobs[j.ID] = j
+	q.order = append(q.order, j.ID)
+
+	q.wg.Add(1)
+	go q.run(ctx, j)
+	return j.Job, nil
+}
+
+// Makes room for one more job by forgetting the oldest finished jobs. Jobs
+// that are queued or running are never forgotten.
+func (q *jobQueue) evict() bool {
+	for len(q.order) >= q.max {
+		evicted := false
+		for i, id := range q.order {
+			if finished(q.jobs[id].Status) {
+				delete(q.jobs, id)
+				q.order = append(q.order[:i], q.order[i+1:]...)
+				evicted = true
+				break
+			}
+		}
+		if !evicted {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *jobQueue) run(ctx context.Context, j *job) {
+	defer q.wg.Done()
+	defer j.cancel()
+
+	select {
+	case q.slots <- struct{}{}:
+		defer func() { <-q.slots }()
+	case <-ctx.Done():
+		q.finish(j, nil, ctx.Err())
+		return
+	}
+
+	q.mu.Lock()
+	if j.Status != StatusQueued {
+		q.mu.Unlock()
+		return
+	}
+	j.Status = StatusRunning
+	started := time.Now().UTC()
+	j.StartedAt = &started
+	q.mu.Unlock()
+
+	scanner, err := scan.New(scan.Options{Settings: j.settings, Baseline: q.baseline})
+	if err != nil {
+		q.finish(j, nil, err)
+		return
+	}
+	result, err := scanner.Scan(ctx, j.Request.Path)
+	if err == nil {
+		findings := len(scanner.Findings(result))
+		j.findings = &findings
+	}
+	q.finish(j, result, err)
+}
+
+func (q *jobQueue) finish(j *job, result *config.SearchResult, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if finished(j.Status) {
+		return
+	}
+	now := time.Now().UTC()
+	j.FinishedAt = &now
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.Status = StatusCanceled
+	case err != nil:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+	default:
+		j.Status = StatusSucceeded
+		j.result = result
+		j.TotalFiles, j.Findings = &result.TotalFiles, j.findings
+	}
+}
+
+// The job with the ID and its result, if it has one
+func (q *jobQueue) get(id string) (Job, *config.SearchResult, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	return j.Job, j.result, true
+}
+
+// Every job kept, oldest first
+func (q *jobQueue) list() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.order))
+	for _, id := range q.order {
+		jobs = append(jobs, q.jobs[id].Job)
+	}
+	return jobs
+}
+
+// Stops a queued or running job; a finished job is left as it is
+func (q *jobQueue) cancel(id string) (Job, bool) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	j.cancel()
+	q.finish(j, nil, context.Canceled)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return j.Job, true
+}
+
+// Cancels every job and waits for them to end
+func (q *jobQueue) shutdown() {
+	q.close()
+	q.wg.Wait()
+}
+
+func finished(status string) bool {
+	return status == StatusSucceeded || status == StatusFailed || status == StatusCanceled
+}
+
+func newJobID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,301 @@
+// Package server exposes scans, certificate checks and file identification
+// over a JSON REST API.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/pkg"
+	"org.gkh/findcert/report"
+)
+
+// How the server runs jobs and what it lets clients scan
+type Options struct {
+	// Defaults for every scan; a request can override the lists
+	Settings config.Settings
+	// Accepted findings, nil for none
+	Baseline *config.Baseline
+	// Directories scans may be made in; any path when empty
+	Roots []string
+	// Scans run at the same time, 1 when zero
+	MaxConcurrent int
+	// Jobs kept, finished or not, 100 when zero
+	MaxJobs int
+	// Largest certificate or file accepted for a check or identification, 10 MiB when zero
+	MaxUploadBytes int64
+}
+
+// The API, as an http.Handler. Close it to cancel the jobs still running.
+type Server struct {
+	opts Options
+	jobs *jobQueue
+	mux  *http.ServeMux
+}
+
+func New(opts Options) *Server {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = 1
+	}
+	if opts.MaxJobs <= 0 {
+		opts.MaxJobs = 100
+	}
+	if opts.MaxUploadBytes <= 0 {
+		opts.MaxUploadBytes = 10 << 20
+	}
+
+	s := &Server{opts: opts, jobs: newJobQueue(opts.MaxConcurrent, opts.MaxJobs, opts.Baseline), mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /v1/scans", s.createScan)
+	s.mux.HandleFunc("GET /v1/scans", s.listScans)
+	s.mux.HandleFunc("GET /v1/scans/{id}", s.getScan)
+	s.mux.HandleFunc("POST /v1/scans/{id}/cancel", s.cancelScan)
+	s.mux.HandleFunc("GET /v1/scans/{id}/results", s.getResults)
+	s.mux.HandleFunc("POST /v1/check", s.check)
+	s.mux.HandleFunc("POST /v1/identify", s.identify)
+	s.mux.HandleFunc("GET /openapi.json", serveOpenAPI)
+	s.mux.HandleFunc("GET /docs", serveDocs)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Cancels every job and waits for the running scans to stop
+func (s *Server) Close() {
+	s.jobs.shutdown()
+}
+
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request) {
+	var request ScanRequest
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid scan request: %w", err))
+		return
+	}
+	if err := s.checkPath(request.Path); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	settings := s.opts.Settings
+	if request.Extensions != nil {
+		settings.Extensions = append([]string(nil), request.Extensions...)
+	}
+	if request.Exclude != nil {
+		settings.Exclude = request.Exclude
+	}
+	if request.Hosts != nil {
+		settings.Policy.Hosts = request.Hosts
+	}
+	if request.AllowedOwners != nil {
+		settings.Policy.AllowedOwners = request.AllowedOwners
+	}
+	if err := settings.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := s.jobs.submit(request, settings)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/scans/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// Accepts an absolute path to an existing directory or file below one of the
+// roots. Symbolic links are followed first, so a link below a root can not
+// lead outside of it.
+func (s *Server) checkPath(path string) error {
+	if path == "" || !filepath.IsAbs(path) {
+		return errors.New("path must be an absolute path")
+	}
+	path = filepath.Clean(path)
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("path does not exist: %s", path)
+	}
+	if len(s.opts.Roots) > 0 {
+		allowed := false
+		for _, root := range s.opts.Roots {
+			root, err := filepath.EvalSymlinks(root)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("path %s is outside the directories this server scans", path)
+		}
+	}
+	return nil
+}
+
+func (s *Server) listScans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.list())
+}
+
+func (s *Server) getScan(w http.ResponseWriter, r *http.Request) {
+	job, _, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such scan"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) cancelScan(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.cancel(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such scan"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// Writes the results of a finished scan in the format asked for, JSON by default
+func (s *Server) getResults(w http.ResponseWriter, r *http.Request) {
+	job, result, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such scan"))
+		return
+	}
+	if result == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("scan is %s, results are only available once it has succeeded", job.Status))
+		return
+	}
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "json"
+	}
+	format, err := report.Lookup(name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType(format))
+	format.Write(w, result)
+}
+
+// The certificates in a PEM or DER request body and their compliance
+type CheckResponse struct {
+	Certificates []config.CertificateInfo `json:"certificates"`
+	// Set when a host was given, one result per leaf
+	Hostnames []cmd.HostnameResult `json:"hostnames,omitempty"`
+}
+
+func (s *Server) check(w http.ResponseWriter, r *http.Request) {
+	data, ok := s.readUpload(w, r)
+	if !ok {
+		return
+	}
+	certs, err := cmd.ParseCertificates(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	checker := cmd.NewChecker(s.opts.Settings)
+	response := CheckResponse{Certificates: []config.CertificateInfo{}}
+	for _, cert := range certs {
+		response.Certificates = append(response.Certificates, cmd.NewCertificateInfo(cert, checker.Check(cert)))
+	}
+	if host := r.URL.Query().Get("host"); host != "" {
+		response.Hostnames = cmd.CheckHostname(certs, host)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) identify(w http.ResponseWriter, r *http.Request) {
+	data, ok := s.readUpload(w, r)
+	if !ok {
+		return
+	}
+
+	// GetFileType reads from a file
+	file, err := os.CreateTemp("", "findcert-identify-")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	filetype, err := pkg.GetFileType(file.Name())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"extension":   filetype.Extension,
+		"mime_type":   filetype.MimeType,
+		"description": filetype.Description,
+	})
+}
+
+// Reads the request body, or writes the error and returns false
+func (s *Server) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.opts.MaxUploadBytes))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("upload must not exceed %d bytes", s.opts.MaxUploadBytes))
+		return nil, false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read upload: %w", err))
+		return nil, false
+	case len(data) == 0:
+		writeError(w, http.StatusBadRequest, errors.New("the request body is empty"))
+		return nil, false
+	}
+	return data, true
+}
+
+func contentType(format *report.Format) string {
+	switch format.Name {
+	case "json", "sarif", "cyclonedx":
+		return "application/json"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "html":
+		return "text/html; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"org.gkh/findcert/config"
+)
+
+func testCertificatePEM(t *testing.T, dnsName string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newTestServer(t *testing.T, opts Options) (*Server, *httptest.Server) {
+	t.Helper()
+	if opts.Settings.Extensions == nil {
+		opts.Settings = config.DefaultSettings()
+	}
+	api := New(opts)
+	ts := httptest.NewServer(api)
+	t.Cleanup(func() {
+		ts.Close()
+		api.Close()
+	})
+	return api, ts
+}
+
+// Sends a request and decodes the JSON response into out, returning the status
+func call(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Failed to decode %s %s: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestScanJob(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "www.pem"), testCertificatePEM(t, "www.example.com"), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	_, ts := newTestServer(t, Options{Roots: []string{dir}})
+
+	var job Job
+	if status := call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+dir+`"}`, &job); status != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", status)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !finished(job.Status) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		call(t, "GET", ts.URL+"/v1/scans/"+job.ID, "", &job)
+	}
+	if job.Status != StatusSucceeded || job.TotalFiles == nil || *job.TotalFiles != 1 {
+		t.Fatalf("Expected the scan to succeed with one file, got %+v", job)
+	}
+
+	var result config.SearchResult
+	if status := call(t, "GET", ts.URL+"/v1/scans/"+job.ID+"/results", "", &result); status != http.StatusOK {
+		t.Fatalf("Expected 200 for the results, got %d", status)
+	}
+	if result.TotalFiles != 1 || result.SearchPath != dir {
+		t.Errorf("Unexpected results %+v", result)
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/scans/" + job.ID + "/results?format=csv")
+	if err != nil {
+		t.Fatalf("Failed to get CSV results: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") || !strings.HasPrefix(string(body), "path,type,subject") {
+		t.Errorf("Expected CSV results, got %s: %q", resp.Header.Get("Content-Type"), body)
+	}
+
+	var jobs []Job
+	if call(t, "GET", ts.URL+"/v1/scans", "", &jobs); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("Expected the job in the list, got %+v", jobs)
+	}
+}
+
+func TestScanJob_RejectsPaths(t *testing.T) {
+	root := t.TempDir()
+	_, ts := newTestServer(t, Options{Roots: []string{root}})
+
+	for _, body := range []string{
+		`{"path": "relative/dir"}`,
+		`{"path": "` + filepath.Dir(root) + `"}`,
+		`{"path": "` + filepath.Join(root, "..", "elsewhere") + `"}`,
+		`{"path": "` + filepath.Join(root, "missing") + `"}`,
+		`{"path": "` + root + `", "unknown": true}`,
+	} {
+		var response map[string]string
+		if status := call(t, "POST", ts.URL+"/v1/scans", body, &response); status != http.StatusBadRequest || response["error"] == "" {
+			t.Errorf("Expected %s to be rejected with an error, got %d %v", body, status, response)
+		}
+	}
+}
+
+func TestScanJob_RejectsSymlinksOutOfRoot(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(outside, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("Symbolic links are not available: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "inside"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	_, ts := newTestServer(t, Options{Roots: []string{root}})
+
+	for _, path := range []string{filepath.Join(root, "link"), filepath.Join(root, "link", "sub")} {
+		var response map[string]string
+		if status := call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+path+`"}`, &response); status != http.StatusBadRequest || response["error"] == "" {
+			t.Errorf("Expected %s to be rejected with an error, got %d %v", path, status, response)
+		}
+	}
+
+	// Links that stay below the root are fine
+	var job Job
+	if status := call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+filepath.Join(root, "alias")+`"}`, &job); status != http.StatusAccepted {
+		t.Errorf("Expected a link within the root to be accepted, got %d", status)
+	}
+}
+
+func TestScanJob_CancelQueued(t *testing.T) {
+	dir := t.TempDir()
+	api, ts := newTestServer(t, Options{MaxConcurrent: 1})
+
+	// Hold the only slot so the job stays queued
+	api.jobs.slots <- struct{}{}
+	defer func() { <-api.jobs.slots }()
+
+	var job Job
+	call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+dir+`"}`, &job)
+	if job.Status != StatusQueued {
+		t.Fatalf("Expected the job to be queued, got %s", job.Status)
+	}
+	if status := call(t, "POST", ts.URL+"/v1/scans/"+job.ID+"/cancel", "", &job); status != http.StatusOK || job.Status != StatusCanceled {
+		t.Fatalf("Expected the job to be canceled, got %d %s", status, job.Status)
+	}
+
+	var response map[string]string
+	if status := call(t, "GET", ts.URL+"/v1/scans/"+job.ID+"/results", "", &response); status != http.StatusConflict {
+		t.Errorf("Expected 409 for the results of a canceled scan, got %d", status)
+	}
+	if status := call(t, "GET", ts.URL+"/v1/scans/unknown", "", &response); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown scan, got %d", status)
+	}
+}
+
+func TestScanJob_QueueLimit(t *testing.T) {
+	dir := t.TempDir()
+	api, ts := newTestServer(t, Options{MaxConcurrent: 1, MaxJobs: 1})
+	api.jobs.slots <- struct{}{}
+	defer func() { <-api.jobs.slots }()
+
+	call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+dir+`"}`, nil)
+	var response map[string]string
+	if status := call(t, "POST", ts.URL+"/v1/scans", `{"path": "`+dir+`"}`, &response); status != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 once the queue is full, got %d", status)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	_, ts := newTestServer(t, Options{})
+
+	var response CheckResponse
+	status := call(t, "POST", ts.URL+"/v1/check?host=api.example.com", string(testCertificatePEM(t, "www.example.com")), &response)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if len(response.Certificates) != 1 || !response.Certificates[0].Compliant {
+		t.Errorf("Expected one compliant certificate, got %+v", response.Certificates)
+	}
+	if len(response.Hostnames) != 1 || response.Hostnames[0].Valid {
+		t.Errorf("Expected the certificate not to be valid for the host, got %+v", response.Hostnames)
+	}
+
+	var failure map[string]string
+	if status := call(t, "POST", ts.URL+"/v1/check", "not a certificate", &failure); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a body without certificates, got %d", status)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	_, ts := newTestServer(t, Options{MaxUploadBytes: 4096})
+
+	var filetype map[string]string
+	if status := call(t, "POST", ts.URL+"/v1/identify", string(testCertificatePEM(t, "www.example.com")), &filetype); status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if filetype["extension"] != ".pem" {
+		t.Errorf("Expected a PEM file, got %v", filetype)
+	}
+
+	var failure map[string]string
+	if status := call(t, "POST", ts.URL+"/v1/identify", string(bytes.Repeat([]byte{0xfe}, 8192)), &failure); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an upload over the limit, got %d", status)
+	}
+}
+
+func TestDocs(t *testing.T) {
+	_, ts := newTestServer(t, Options{})
+
+	var doc map[string]any
+	if status := call(t, "GET", ts.URL+"/openapi.json", "", &doc); status != http.StatusOK || doc["openapi"] != "3.1.0" {
+		t.Errorf("Expected the OpenAPI document, got %d", status)
+	}
+
+	resp, err := http.Get(ts.URL + "/docs")
+	if err != nil {
+		t.Fatalf("Failed to get docs: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "<code>/v1/scans/{id}/cancel</code>") {
+		t.Errorf("Expected every path in the docs page, got %s", body)
+	}
+}
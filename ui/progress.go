@@ -30,15 +30,15 @@ func (s *Spinner) Start(message string) {
 
 	go func() {
 		for i := 0; ; i = (i + 1) % len(spinnerChars) {
+			// Print under the lock so Stop's clearing of the line comes last
 			s.Lock()
 			if s.Stopped {
 				s.Unlock()
 				return
 			}
-			s.Unlock()
-
 			// Clear line and print spinner
 			fmt.Printf("\r\033[K%s %s", spinnerChars[i], message)
+			s.Unlock()
 
 			select {
 			case <-s.stop:
@@ -0,0 +1,19 @@
+package ui
+
+import (
+	"io"
+	"os"
+)
+
+// Is the writer an interactive terminal, where a spinner can redraw its line?
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
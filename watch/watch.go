@@ -0,0 +1,555 @@
+// Package watch follows directory trees for certificates, key stores and CRLs
+// being added, replaced or removed, and checks every changed file as soon as
+// the change has settled. It uses file system notifications where the
+// platform has them and polls otherwise.
+package watch
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"org.gkh/findcert/cmd"
+	"org.gkh/findcert/config"
+	"org.gkh/findcert/scan"
+)
+
+// Kinds of change
+const (
+	Added    = "added"
+	Replaced = "replaced"
+	Removed  = "removed"
+)
+
+// A file that changed and what it holds now
+type Event struct {
+	// One of added, replaced or removed
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path"`
+	// The extension the file was found with
+	Extension string `json:"extension"`
+	// The file as it is now, or as it was before it was removed
+	File *config.FileInfo `json:"file"`
+	// The findings of the file as it is now, none for removed files
+	Findings []config.LocatedFinding `json:"findings,omitempty"`
+	// Fingerprints of the certificates the change brought and took away
+	AddedCertificates   []string `json:"added_certificates,omitempty"`
+	RemovedCertificates []string `json:"removed_certificates,omitempty"`
+}
+
+// What a Watcher follows and whom it tells
+type Options struct {
+	// Extensions, exclusions, thresholds and policy. The zero value means
+	// config.DefaultSettings(). Host coverage needs a whole tree and is not
+	// checked.
+	Settings config.Settings
+	// Accepted findings, nil for none
+	Baseline *config.Baseline
+	// Polls instead of using file system notifications
+	Poll bool
+	// How often to poll, 10 seconds when zero
+	PollInterval time.Duration
+	// How long a file must be left alone before it is checked, so that a
+	// file being written is checked once; half a second when zero
+	Settle time.Duration
+	// Called with every change. An error stops the watcher.
+	OnEvent func(event Event) error
+	// Called when file system notifications are unavailable, with the
+	// reason, before the watcher falls back to polling
+	OnFallback func(err error)
+	// Called once the files present at the start have been checked and
+	// changes are being followed
+	OnReady func()
+}
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultSettle       = 500 * time.Millisecond
+)
+
+// Follows directory trees for changes. The files present when Run starts are
+// checked but not reported; only the changes after that are.
+type Watcher struct {
+	opts    Options
+	scanner *scan.Scanner
+	list    cmd.ListOptions
+	roots   []string
+	// The files last seen, by path
+	files map[string]*fileState
+	// The CA certificates and CRLs last seen, by path, that changed files
+	// are checked against
+	authorities map[string][]*x509.Certificate
+	crls        map[string]*x509.RevocationList
+	// Set while notifications are used, to watch new directories
+	notifier *fsnotify.Watcher
+}
+
+// A file as last checked
+type fileState struct {
+	extension string
+	file      config.FileInfo
+}
+
+// Checks the settings and returns a watcher for them
+func New(opts Options) (*Watcher, error) {
+	if opts.PollInterval == 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Settle == 0 {
+		opts.Settle = defaultSettle
+	}
+	settings := opts.Settings
+	if reflect.ValueOf(settings).IsZero() {
+		settings = config.DefaultSettings()
+	}
+	settings.Policy.Hosts = nil
+	scanner, err := scan.New(scan.Options{Settings: settings, Baseline: opts.Baseline})
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		opts:    opts,
+		scanner: scanner,
+		list:    cmd.ListOptions{Extensions: settings.Extensions, Exclude: settings.Exclude},
+		files:   make(map[string]*fileState),
+
+		authorities: make(map[string][]*x509.Certificate),
+		crls:        make(map[string]*x509.RevocationList),
+	}, nil
+}
+
+// Checks the files below roots and then reports every change to them until
+// ctx is done. A Watcher can only run once.
+func (w *Watcher) Run(ctx context.Context, roots []string) error {
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return err
+		}
+		w.roots = append(w.roots, abs)
+	}
+	for _, root := range w.roots {
+		if err := w.checkAll(ctx, root); err != nil {
+			return stopped(ctx, err)
+		}
+	}
+
+	var err error
+	if !w.opts.Poll {
+		if err = w.startNotifications(); err == nil {
+			defer w.notifier.Close()
+		} else if w.opts.OnFallback != nil {
+			w.opts.OnFallback(err)
+		}
+	}
+	if w.opts.OnReady != nil {
+		w.opts.OnReady()
+	}
+	if w.notifier != nil {
+		err = w.followNotifications(ctx)
+	} else {
+		err = w.followPolling(ctx)
+	}
+	return stopped(ctx, err)
+}
+
+// A watcher stopped by its context has done its job
+func stopped(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// Records the files below root as they are now, without reporting them
+func (w *Watcher) checkAll(ctx context.Context, root string) error {
+	result, err := w.scanner.Scan(ctx, root)
+	if err != nil {
+		return err
+	}
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			w.files[file.Path] = &fileState{extension: extResult.Type, file: file}
+			w.remember(extResult.Type, &file)
+		}
+	}
+	return nil
+}
+
+// Keeps the CA certificates or the CRL of a file for checking other files
+func (w *Watcher) remember(extension string, file *config.FileInfo) {
+	w.forget(file.Path)
+	if extension == ".crl" {
+		if crl, err := cmd.LoadCRL(file.Path); err == nil {
+			w.crls[file.Path] = crl
+		}
+		return
+	}
+
+	// Only files with a CA certificate are read again
+	ca := false
+	for _, cert := range file.Certificates {
+		ca = ca || cert.IsCA
+	}
+	if !ca {
+		return
+	}
+	contents, err := cmd.ReadContents(extension, file.Path)
+	if err != nil {
+		return
+	}
+	for _, der := range contents.Certificates {
+		if cert, err := x509.ParseCertificate(der); err == nil && cert.IsCA {
+			w.authorities[file.Path] = append(w.authorities[file.Path], cert)
+		}
+	}
+}
+
+func (w *Watcher) forget(path string) {
+	delete(w.authorities, path)
+	delete(w.crls, path)
+}
+
+// The CA certificates and CRLs of every file but the one at path
+func (w *Watcher) known(path string) ([]*x509.Certificate, []*x509.RevocationList) {
+	var paths []string
+	for known := range w.authorities {
+		paths = append(paths, known)
+	}
+	for known := range w.crls {
+		paths = append(paths, known)
+	}
+	// In a fixed order, for the same CRL issuer every time
+	sort.Strings(paths)
+
+	var issuers []*x509.Certificate
+	var crls []*x509.RevocationList
+	for _, known := range paths {
+		if known == path {
+			continue
+		}
+		issuers = append(issuers, w.authorities[known]...)
+		if crl := w.crls[known]; crl != nil {
+			crls = append(crls, crl)
+		}
+	}
+	return issuers, crls
+}
+
+// Watches every directory below the roots
+func (w *Watcher) startNotifications() error {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.notifier = notifier
+	for _, root := range w.roots {
+		if err := w.watchTree(root, root); err != nil {
+			notifier.Close()
+			w.notifier = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) watchTree(root, dir string) error {
+	return w.walk(root, dir, func(path string) error {
+		return w.notifier.Add(path)
+	}, nil)
+}
+
+// Collects the paths notifications name and checks them once they have been
+// left alone for the settle time
+func (w *Watcher) followNotifications(ctx context.Context) error {
+	dirty := make(map[string]bool)
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.notifier.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				// A moved directory would otherwise be reported under its old name
+				w.notifier.Remove(event.Name)
+			}
+			dirty[event.Name] = true
+			settled = time.After(w.opts.Settle)
+		case err, ok := <-w.notifier.Errors:
+			if !ok {
+				return nil
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return err
+			}
+			// Changes were lost, so look at everything
+			if err := w.poll(ctx); err != nil {
+				return err
+			}
+		case <-settled:
+			settled = nil
+			paths := make([]string, 0, len(dirty))
+			for path := range dirty {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			clear(dirty)
+			for _, path := range paths {
+				if err := w.check(ctx, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (w *Watcher) followPolling(ctx context.Context) error {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.poll(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Checks the files whose size or modification time changed since they were
+// last seen, and the files that are gone
+func (w *Watcher) poll(ctx context.Context) error {
+	seen := make(map[string]bool)
+	for _, root := range w.roots {
+		err := w.walk(root, root, nil, func(path string, info fs.FileInfo) error {
+			seen[path] = true
+			if known := w.files[path]; known != nil &&
+				known.file.Size == info.Size() && known.file.ModifiedTime.Equal(info.ModTime()) {
+				return nil
+			}
+			return w.checkFile(ctx, root, path)
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	for _, path := range w.knownWithin("") {
+		if !seen[path] {
+			if err := w.remove(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Checks a path a notification named: a file, or a directory that appeared
+// or went away together with the files in it
+func (w *Watcher) check(ctx context.Context, path string) error {
+	root, ok := w.rootOf(path)
+	if !ok || w.list.Skips(root, path) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return w.checkFile(ctx, root, path)
+	}
+
+	seen := make(map[string]bool)
+	if err == nil {
+		if w.notifier != nil {
+			if err := w.watchTree(root, path); err != nil {
+				return err
+			}
+		}
+		err := w.walk(root, path, nil, func(file string, _ fs.FileInfo) error {
+			seen[file] = true
+			return w.checkFile(ctx, root, file)
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	for _, known := range w.knownWithin(path) {
+		if !seen[known] {
+			if err := w.remove(known); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Inspects a file below root and reports it if it is new or has changed
+func (w *Watcher) checkFile(ctx context.Context, root, path string) error {
+	if _, ok := w.list.Match(path); !ok {
+		return nil
+	}
+	// Checked against the rest of the tree, so that a replaced CRL can be
+	// verified and a replaced certificate found revoked
+	issuers, crls := w.known(path)
+	result, err := w.scanner.ScanWith(ctx, path, issuers, crls)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return w.remove(path)
+		}
+		return err
+	}
+	var current *fileState
+	for _, extResult := range result.Results {
+		for _, file := range extResult.Files {
+			current = &fileState{extension: extResult.Type, file: file}
+		}
+	}
+	if current == nil {
+		return w.remove(path)
+	}
+
+	previous := w.files[path]
+	w.files[path] = current
+	w.remember(current.extension, &current.file)
+	event := Event{
+		Type:      Added,
+		Time:      time.Now(),
+		Path:      path,
+		Extension: current.extension,
+		File:      &current.file,
+		Findings:  w.scanner.Findings(result),
+	}
+	if previous == nil {
+		event.AddedCertificates = fingerprints(&current.file, nil)
+		return w.emit(event)
+	}
+	event.Type = Replaced
+	event.AddedCertificates = fingerprints(&current.file, &previous.file)
+	event.RemovedCertificates = fingerprints(&previous.file, &current.file)
+	if previous.file.Size == current.file.Size && previous.file.ModifiedTime.Equal(current.file.ModifiedTime) &&
+		len(event.AddedCertificates) == 0 && len(event.RemovedCertificates) == 0 {
+		// Touched without being changed, such as by a change of permissions
+		return nil
+	}
+	return w.emit(event)
+}
+
+// Reports a file that is gone
+func (w *Watcher) remove(path string) error {
+	previous := w.files[path]
+	if previous == nil {
+		return nil
+	}
+	delete(w.files, path)
+	w.forget(path)
+	return w.emit(Event{
+		Type:                Removed,
+		Time:                time.Now(),
+		Path:                path,
+		Extension:           previous.extension,
+		File:                &previous.file,
+		RemovedCertificates: fingerprints(&previous.file, nil),
+	})
+}
+
+func (w *Watcher) emit(event Event) error {
+	if w.opts.OnEvent == nil {
+		return nil
+	}
+	return w.opts.OnEvent(event)
+}
+
+// Calls onDir with every directory and onFile with every file a scan would
+// inspect below dir, which lies in root. Entries that can not be read are
+// left out, except dir itself.
+func (w *Watcher) walk(root, dir string, onDir func(path string) error, onFile func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if w.list.Skips(root, path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if onDir != nil {
+				return onDir(path)
+			}
+			return nil
+		}
+		if _, ok := w.list.Match(path); !ok || onFile == nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		return onFile(path, info)
+	})
+}
+
+// The root path lies in, the innermost if roots are nested
+func (w *Watcher) rootOf(path string) (string, bool) {
+	best := ""
+	for _, root := range w.roots {
+		if (path == root || isWithin(root, path)) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best, best != ""
+}
+
+// The files last seen at path or below it, every file for an empty path
+func (w *Watcher) knownWithin(path string) []string {
+	var paths []string
+	for known := range w.files {
+		if path == "" || known == path || isWithin(path, known) {
+			paths = append(paths, known)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Is path below dir?
+func isWithin(dir, path string) bool {
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// The fingerprints of the certificates in file that are not in other
+func fingerprints(file, other *config.FileInfo) []string {
+	have := make(map[string]bool)
+	if other != nil {
+		for _, cert := range other.Certificates {
+			have[cert.Fingerprint] = true
+		}
+	}
+	var result []string
+	for _, cert := range file.Certificates {
+		if !have[cert.Fingerprint] {
+			result = append(result, cert.Fingerprint)
+			have[cert.Fingerprint] = true
+		}
+	}
+	return result
+}
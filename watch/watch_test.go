@@ -0,0 +1,229 @@
+package watch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Writes a new self-signed certificate for name to path
+func writeCertificate(t *testing.T, path, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+}
+
+// Runs a watcher on dir and returns its events
+func startWatcher(t *testing.T, dir string, opts Options) <-chan Event {
+	t.Helper()
+
+	events := make(chan Event, 10)
+	ready := make(chan struct{})
+	opts.Settle = 20 * time.Millisecond
+	opts.PollInterval = 20 * time.Millisecond
+	opts.OnEvent = func(event Event) error {
+		events <- event
+		return nil
+	}
+	opts.OnReady = func() { close(ready) }
+	watcher, err := New(opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Run(ctx, []string{dir}) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("Run stopped before it was ready: %v", err)
+	}
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func testAddReplaceRemove(t *testing.T, opts Options) {
+	dir := t.TempDir()
+	writeCertificate(t, filepath.Join(dir, "existing.crt"), "existing.example.com")
+	events := startWatcher(t, dir, opts)
+
+	path := filepath.Join(dir, "www.crt")
+	writeCertificate(t, path, "www.example.com")
+	event := nextEvent(t, events)
+	if event.Type != Added || event.Path != path || len(event.AddedCertificates) != 1 {
+		t.Fatalf("Expected www.crt to be added with its certificate, got %+v", event)
+	}
+	if len(event.File.Certificates) != 1 || event.File.Certificates[0].Subject != "CN=www.example.com" {
+		t.Errorf("Expected the certificate to be inspected, got %+v", event.File.Certificates)
+	}
+	first := event.AddedCertificates[0]
+
+	// Make sure the replacement has another modification time for polling
+	time.Sleep(10 * time.Millisecond)
+	writeCertificate(t, path, "www.example.com")
+	event = nextEvent(t, events)
+	if event.Type != Replaced || len(event.AddedCertificates) != 1 || len(event.RemovedCertificates) != 1 || event.RemovedCertificates[0] != first {
+		t.Fatalf("Expected www.crt to be replaced, got %+v", event)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	event = nextEvent(t, events)
+	if event.Type != Removed || event.Path != path || len(event.RemovedCertificates) != 1 {
+		t.Fatalf("Expected www.crt to be removed, got %+v", event)
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no more events, got %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatcher_Notifications(t *testing.T) {
+	testAddReplaceRemove(t, Options{OnFallback: func(err error) { t.Logf("Polling without file system notifications: %v", err) }})
+}
+
+func TestWatcher_Polling(t *testing.T) {
+	testAddReplaceRemove(t, Options{Poll: true})
+}
+
+func TestWatcher_NewDirectory(t *testing.T) {
+	dir := t.TempDir()
+	events := startWatcher(t, dir, Options{OnFallback: func(err error) { t.Logf("Polling without file system notifications: %v", err) }})
+
+	sub := filepath.Join(dir, "app", "certs")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeCertificate(t, filepath.Join(sub, "app.pem"), "app.example.com")
+	event := nextEvent(t, events)
+	if event.Type != Added || event.Path != filepath.Join(sub, "app.pem") {
+		t.Fatalf("Expected the certificate in the new directory to be added, got %+v", event)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "app")); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, events); event.Type != Removed {
+		t.Fatalf("Expected the certificate to be removed with its directory, got %+v", event)
+	}
+}
+
+func TestWatcher_ChecksChangesAgainstTree(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Watch CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeCRL := func(number int64, revoked ...int64) {
+		t.Helper()
+		list := &x509.RevocationList{Number: big.NewInt(number), ThisUpdate: time.Now(), NextUpdate: time.Now().Add(time.Hour)}
+		for _, serial := range revoked {
+			list.RevokedCertificateEntries = append(list.RevokedCertificateEntries, x509.RevocationListEntry{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+		}
+		data, err := x509.CreateRevocationList(rand.Reader, list, ca, key)
+		if err != nil {
+			t.Fatalf("Failed to create CRL: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "ca.crl"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCRL(1)
+	events := startWatcher(t, dir, Options{Poll: true})
+	rules := func(event Event) map[string]bool {
+		found := make(map[string]bool)
+		for _, finding := range event.Findings {
+			found[finding.RuleID] = true
+		}
+		return found
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	writeCRL(2, 0x0102030405060708)
+	event := nextEvent(t, events)
+	if event.Type != Replaced || rules(event)["crl-unverified"] {
+		t.Fatalf("Expected the replaced CRL to be verified by the CA in the tree, got %+v", event)
+	}
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(0x0102030405060708),
+		Subject:      pkix.Name{CommonName: "revoked.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}, ca, &leafKey.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "revoked.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	event = nextEvent(t, events)
+	if event.Type != Added || !rules(event)["revoked"] {
+		t.Fatalf("Expected the new certificate to be found revoked by the CRL in the tree, got %+v", event)
+	}
+}